@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/ignore"
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//Stage given files and directories
+//Every file is saved as a blob and the index file
+//is rewritten once with all new entries
+func Add(
+	gitRepoPath string,
+	workTree string,
+	paths []string,
+	store repository.ObjectStore,
+) ([]index.Entry, error) {
+	matcher, err := newIgnoreMatcher(gitRepoPath, workTree)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, path := range paths {
+		if !workTreeExists(path) {
+			return nil, errors.New(
+				fmt.Sprintf(
+					"pathspec '%s' did not match any files",
+					path,
+				),
+			)
+		}
+		ignored, err := pathIgnored(matcher, workTree, path)
+		if err != nil {
+			return nil, err
+		}
+		if ignored {
+			return nil, errors.New(
+				fmt.Sprintf(
+					"The following path is ignored by one of your %s files: %s",
+					ignore.FileName,
+					path,
+				),
+			)
+		}
+		err = walkWorkTree(
+			path,
+			gitRepoPath,
+			workTree,
+			func(file string, info os.FileInfo) error {
+				files = append(files, file)
+				return nil
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var added []index.Entry
+	for _, file := range files {
+		entry, err := stageFile(file, workTree, store)
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, *entry)
+	}
+	indexPath := repository.IndexPath(gitRepoPath)
+	idx, err := index.Read(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	idx.Add(added...)
+	return added, idx.Write(indexPath)
+}
+
+//save a single file as a blob and create an index entry for it
+func stageFile(
+	file string,
+	workTree string,
+	store repository.ObjectStore,
+) (*index.Entry, error) {
+	relative, err := relativePath(workTree, file)
+	if err != nil {
+		return nil, err
+	}
+	content, info, err := readWorkTreeFile(file)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := store.Put(repository.BLOB, content)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := index.StatFile(file)
+	if err != nil {
+		return nil, err
+	}
+	entry := index.NewEntry(relative, repository.FileMode(info), blob, stat)
+	return &entry, nil
+}
+
+//path of a file relative to the working tree
+func relativePath(workTree string, file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	relative, err := filepath.Rel(workTree, abs)
+	if err != nil {
+		return "", err
+	}
+	if relative == ".." || strings.HasPrefix(relative, ".."+string(os.PathSeparator)) {
+		return "", errors.New(
+			fmt.Sprintf(
+				"'%s' is outside of the repository",
+				file,
+			),
+		)
+	}
+	return relative, nil
+}
+
+//visit every regular file and symbolic link under given root,
+//the repository directory itself and ignored paths are skipped
+func walkWorkTree(
+	root string,
+	gitRepoPath string,
+	workTree string,
+	visit func(file string, info os.FileInfo) error,
+) error {
+	matcher, err := newIgnoreMatcher(gitRepoPath, workTree)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(
+		root,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && (path == filepath.Clean(gitRepoPath) || info.Name() == repository.RepoDir) {
+				return filepath.SkipDir
+			}
+			ignored, err := pathIgnored(matcher, workTree, path)
+			if err != nil {
+				return err
+			}
+			if ignored {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if !info.Mode().IsRegular() && info.Mode()&os.ModeSymlink == 0 {
+				return nil
+			}
+			return visit(path, info)
+		},
+	)
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestAdd(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	err = os.MkdirAll(dir+"/src/inner", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/src/main.go", []byte("package main"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/src/inner/run.sh", []byte("echo run"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := Add(gitDir, dir, []string{dir + "/src"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 {
+		t.Fatalf("Wrong amount of staged files, 2 expected, got %d", len(added))
+	}
+	idx, err := index.Read(repository.IndexPath(gitDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := idx.Entries()
+	modes := make(map[string]repository.Mode)
+	for _, entry := range entries {
+		modes[entry.Path()] = entry.Mode()
+		if !store.Has(entry.Hash()) {
+			t.Fatalf("Blob for '%s' was not saved", entry.Path())
+		}
+	}
+	if modes[filepath.Join("src", "main.go")] != repository.FILE {
+		t.Fatalf("Wrong mode for main.go, got '%s'", modes["src/main.go"])
+	}
+	if modes[filepath.Join("src", "inner", "run.sh")] != repository.EXECUTABLE {
+		t.Fatalf("Wrong mode for run.sh, got '%s'", modes["src/inner/run.sh"])
+	}
+	//adding again must replace entries instead of duplicating them
+	_, err = Add(gitDir, dir, []string{dir + "/src/main.go"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err = index.Read(repository.IndexPath(gitDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = idx.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Wrong amount of index entries, 2 expected, got %d", len(entries))
+	}
+}
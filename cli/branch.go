@@ -0,0 +1,344 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//a branch with its tip commit
+type BranchInfo struct {
+	Name    string
+	Current bool
+	Commit  repository.Hash
+	Subject string //the first line of the tip commit's message
+}
+
+//Name of the current branch, fails if HEAD is detached
+func Branch(
+	gitRepoPath string,
+	reader repository.FileReader,
+) (string, error) {
+	branch, detached, err := readHead(gitRepoPath, reader)
+	if err != nil {
+		return "", errors.New(
+			`There is no branch in this repo,
+            to create one use 'dzhigit update-ref'
+            and then check it out using 'dzhigit checkout' `,
+		)
+	}
+	if len(detached) != 0 {
+		return "", errors.New(
+			fmt.Sprintf("HEAD is detached at %s, not on a branch", string(detached)[0:7]),
+		)
+	}
+	return branch, nil
+}
+
+//List all branches under refs/heads sorted by name
+//A detached HEAD is listed first as the current entry
+func ListBranches(
+	gitRepoPath string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) ([]BranchInfo, error) {
+	current, detached, err := readHead(gitRepoPath, reader)
+	if err != nil {
+		return nil, err
+	}
+	headsPath := repository.HeadsPath(gitRepoPath)
+	var branches []BranchInfo
+	if len(detached) != 0 {
+		commit, err := readCommit(detached, store)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(
+			branches,
+			BranchInfo{
+				Name:    fmt.Sprintf("(HEAD detached at %s)", string(detached)[0:7]),
+				Current: true,
+				Commit:  detached,
+				Subject: strings.SplitN(commit.message, "\n", 2)[0],
+			},
+		)
+	}
+	//the detached HEAD stays on top
+	detachedEntries := len(branches)
+	err = filepath.Walk(
+		headsPath,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			//lock of a ref that is being written by another process
+			if repository.IsLockFile(path) {
+				return nil
+			}
+			name, err := filepath.Rel(headsPath, path)
+			if err != nil {
+				return err
+			}
+			tip, err := branchTip(gitRepoPath, name, reader)
+			if err != nil {
+				return err
+			}
+			commit, err := readCommit(tip, store)
+			if err != nil {
+				return err
+			}
+			branches = append(
+				branches,
+				BranchInfo{
+					Name:    name,
+					Current: name == current,
+					Commit:  tip,
+					Subject: strings.SplitN(commit.message, "\n", 2)[0],
+				},
+			)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	named := branches[detachedEntries:]
+	sort.Slice(named, func(i, j int) bool {
+		return named[i].Name < named[j].Name
+	})
+	return branches, nil
+}
+
+//Create a branch that points to given start commit
+//start is a revision, an empty start means HEAD
+func CreateBranch(
+	gitRepoPath string,
+	name string,
+	start string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) error {
+	err := repository.ValidateRefName(name)
+	if err != nil {
+		return err
+	}
+	if repository.Exists(repository.HeadsPath(gitRepoPath) + name) {
+		return errors.New(
+			fmt.Sprintf("A branch named '%s' already exists", name),
+		)
+	}
+	if len(start) == 0 {
+		start = "HEAD"
+	}
+	commitHash, err := ResolveCommit(gitRepoPath, start, reader, store)
+	if err != nil {
+		return err
+	}
+	return writeBranch(
+		gitRepoPath,
+		name,
+		"",
+		commitHash,
+		"branch: Created from "+start,
+		reader,
+		store,
+	)
+}
+
+//Point a branch to given commit, the branch is created if it doesn't exist
+func UpdateBranch(
+	gitRepoPath string,
+	name string,
+	commitHash repository.Hash,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) error {
+	err := repository.ValidateRefName(name)
+	if err != nil {
+		return err
+	}
+	var old repository.Hash
+	if repository.Exists(repository.HeadsPath(gitRepoPath) + name) {
+		old, err = branchTip(gitRepoPath, name, reader)
+		if err != nil {
+			return err
+		}
+	}
+	return writeBranch(gitRepoPath, name, old, commitHash, "update-ref", reader, store)
+}
+
+//Delete a branch together with its log
+//A branch that is not merged into HEAD is deleted only with force
+func DeleteBranch(
+	gitRepoPath string,
+	name string,
+	force bool,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	//a name like "../tags/v1" would point outside of branches
+	err := repository.ValidateRefName(name)
+	if err != nil {
+		return "", err
+	}
+	current, err := currentBranch(gitRepoPath, reader)
+	if err != nil {
+		return "", err
+	}
+	if name == current {
+		return "", errors.New(
+			fmt.Sprintf("Cannot delete branch '%s' checked out", name),
+		)
+	}
+	tip, err := branchTip(gitRepoPath, name, reader)
+	if err != nil {
+		return "", err
+	}
+	if !force {
+		head, err := headCommit(gitRepoPath, reader)
+		if err != nil {
+			return "", err
+		}
+		merged, _, err := ancestors(head, store)
+		if err != nil {
+			return "", err
+		}
+		if !merged[tip] {
+			return "", errors.New(
+				fmt.Sprintf(
+					"The branch '%s' is not fully merged, use -D to delete it anyway",
+					name,
+				),
+			)
+		}
+	}
+	err = removeWorkTreeFile(repository.LogPath(gitRepoPath, branchRef("")), name)
+	if err != nil {
+		return "", err
+	}
+	return tip, removeWorkTreeFile(repository.HeadsPath(gitRepoPath), name)
+}
+
+//Rename a branch, HEAD follows the current branch
+func RenameBranch(
+	gitRepoPath string,
+	oldName string,
+	newName string,
+	reader repository.FileReader,
+) error {
+	err := repository.ValidateRefName(oldName)
+	if err != nil {
+		return err
+	}
+	err = repository.ValidateRefName(newName)
+	if err != nil {
+		return err
+	}
+	headsPath := repository.HeadsPath(gitRepoPath)
+	if !repository.Exists(headsPath + oldName) {
+		return errors.New(
+			fmt.Sprintf("error branch with name '%s' doesn't exist", oldName),
+		)
+	}
+	if repository.Exists(headsPath + newName) {
+		return errors.New(
+			fmt.Sprintf("A branch named '%s' already exists", newName),
+		)
+	}
+	current, err := currentBranch(gitRepoPath, reader)
+	if err != nil {
+		return err
+	}
+	//both branches are locked, so a concurrent update of the old one is not lost
+	//and the new one is only created if it still doesn't exist
+	oldLock, err := repository.LockFile(headsPath + oldName)
+	if err != nil {
+		return err
+	}
+	content, err := reader(headsPath + oldName)
+	if err != nil {
+		oldLock.Rollback()
+		return err
+	}
+	err = repository.CompareAndSwap(headsPath+newName, nil, content, 0755)
+	if err != nil {
+		oldLock.Rollback()
+		return err
+	}
+	err = os.Remove(headsPath + oldName)
+	oldLock.Rollback()
+	if err != nil {
+		return err
+	}
+	//remove directories of the old name that became empty
+	err = removeWorkTreeFile(headsPath, oldName)
+	if err != nil {
+		return err
+	}
+	//the history of the branch moves with it
+	logsPath := repository.LogPath(gitRepoPath, branchRef(""))
+	if repository.Exists(logsPath + oldName) {
+		err = os.MkdirAll(filepath.Dir(logsPath+newName), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(logsPath+oldName, logsPath+newName)
+		if err != nil {
+			return err
+		}
+		err = removeWorkTreeFile(logsPath, oldName)
+		if err != nil {
+			return err
+		}
+	}
+	tip, err := branchTip(gitRepoPath, newName, reader)
+	if err != nil {
+		return err
+	}
+	err = appendReflog(
+		gitRepoPath,
+		branchRef(newName),
+		tip,
+		tip,
+		fmt.Sprintf("Branch: renamed %s to %s", branchRef(oldName), branchRef(newName)),
+		reader,
+	)
+	if err != nil {
+		return err
+	}
+	if current == oldName {
+		return repository.WriteLocked(
+			repository.HeadPath(gitRepoPath),
+			[]byte(headContent(newName)),
+			0755,
+		)
+	}
+	return nil
+}
+
+//hash of a commit given branch points to
+func branchTip(
+	gitRepoPath string,
+	name string,
+	reader repository.FileReader,
+) (repository.Hash, error) {
+	pathToBranch := repository.HeadsPath(gitRepoPath) + name
+	if !repository.Exists(pathToBranch) {
+		return "", errors.New(
+			fmt.Sprintf(
+				"error branch with name '%s' doesn't exist",
+				name,
+			),
+		)
+	}
+	content, err := reader(pathToBranch)
+	if err != nil {
+		return "", err
+	}
+	return repository.NewHash(strings.TrimSpace(string(content)))
+}
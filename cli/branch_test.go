@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestBranch(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	branches, err := ListBranches(gitDir, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 0 {
+		t.Fatalf("A repository without commits has no branches, got %v", branches)
+	}
+	err = os.WriteFile(dir+"/file", []byte("first"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFiles(t, gitDir, "First commit", dir+"/file")
+	err = CreateBranch(gitDir, "feature/login", "", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CreateBranch(gitDir, "feature/login", "", repository.Reader, store)
+	if err == nil {
+		t.Fatal("Branch with existing name was created")
+	}
+	err = CreateBranch(gitDir, "bad..name", "", repository.Reader, store)
+	if err == nil {
+		t.Fatal("Branch with invalid name was created")
+	}
+	err = os.WriteFile(dir+"/file", []byte("second"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := commitFiles(t, gitDir, "Second commit\n\nWith body", dir+"/file")
+	//a branch from a revision and a branch from another branch
+	err = CreateBranch(gitDir, "old", "HEAD~1", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CreateBranch(gitDir, "copy", "master", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches, err = ListBranches(gitDir, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []BranchInfo{
+		{Name: "copy", Commit: second, Subject: "Second commit"},
+		{Name: "feature/login", Commit: first, Subject: "First commit"},
+		{Name: "master", Current: true, Commit: second, Subject: "Second commit"},
+		{Name: "old", Commit: first, Subject: "First commit"},
+	}
+	if len(branches) != len(expected) {
+		t.Fatalf("Expected %d branches, got %v", len(expected), branches)
+	}
+	for i := range expected {
+		if branches[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], branches[i])
+		}
+	}
+	_, err = DeleteBranch(gitDir, "master", true, repository.Reader, store)
+	if err == nil {
+		t.Fatal("The current branch was deleted")
+	}
+	err = RenameBranch(gitDir, "master", "main", repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := currentBranch(gitDir, repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != "main" {
+		t.Fatalf("HEAD wasn't moved to the renamed branch, got %s", current)
+	}
+	//a branch with commits that are not in HEAD
+	err = Checkout(gitDir, dir, "old", false, repository.Reader, store, &formatter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DeleteBranch(gitDir, "copy", false, repository.Reader, store)
+	if err == nil {
+		t.Fatal("Unmerged branch was deleted without force")
+	}
+	tip, err := DeleteBranch(gitDir, "copy", true, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip != second {
+		t.Fatalf("Expected deleted tip %s, got %s", second, tip)
+	}
+	_, err = DeleteBranch(gitDir, "feature/login", false, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repository.Exists(repository.HeadsPath(gitDir) + "feature") {
+		t.Fatal("Empty directory of a deleted branch was kept")
+	}
+	//names that point outside of branches
+	err = os.WriteFile(repository.TagsPath(gitDir)+"v1", []byte(first), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DeleteBranch(gitDir, "../tags/v1", true, repository.Reader, store)
+	if err == nil || !repository.Exists(repository.TagsPath(gitDir)+"v1") {
+		t.Fatal("A tag was deleted as a branch")
+	}
+	err = RenameBranch(gitDir, "../tags/v1", "stolen", repository.Reader)
+	if err == nil || !repository.Exists(repository.TagsPath(gitDir)+"v1") {
+		t.Fatal("A tag was renamed as a branch")
+	}
+	_, err = Merge(
+		gitDir,
+		dir,
+		"../tags/v1",
+		&User{Name: "Almas", Email: "almas337519@gmail.com"},
+		CurrentTime(),
+		&formatter,
+		repository.Reader,
+		store,
+	)
+	if err == nil {
+		t.Fatal("A tag was merged as a branch")
+	}
+	//a branch that is being updated by another process
+	lock, err := repository.LockFile(repository.HeadsPath(gitDir) + "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RenameBranch(gitDir, "old", "renamed", repository.Reader)
+	lock.Rollback()
+	if err == nil || !repository.Exists(repository.HeadsPath(gitDir)+"old") {
+		t.Fatal("A locked branch was renamed")
+	}
+}
+
+func Test_branchNameFromNestedHead(t *testing.T) {
+	name := branchNameFromHead(headContent("feature/login"))
+	if name != "feature/login" {
+		t.Fatalf("Expected feature/login, got %s", name)
+	}
+}
@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//Switch the working tree, the index and HEAD to given branch or commit
+//A target that is not a branch name is resolved as a revision
+//and HEAD is detached at the resolved commit.
+//Only files that differ between the current HEAD and the target are touched,
+//files absent from the target are removed with directories that became empty.
+//Checkout is aborted if any affected file has uncommitted changes,
+//with force local changes are discarded instead
+func Checkout(
+	gitRepoPath string,
+	workTree string,
+	target string,
+	force bool,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+	formatter repository.GitFileFormatter,
+) error {
+	headsPath := repository.HeadsPath(gitRepoPath)
+	var branchName string
+	var newCommit repository.Hash
+	var err error
+	if repository.ValidateRefName(target) == nil && repository.Exists(headsPath+target) {
+		branchName = target
+		newCommit, err = branchTip(gitRepoPath, branchName, reader)
+	} else {
+		newCommit, err = ResolveCommit(gitRepoPath, target, reader, store)
+	}
+	if err != nil {
+		return err
+	}
+	commit, err := readCommit(newCommit, store)
+	if err != nil {
+		return err
+	}
+	treeHash := commit.treeHash
+	current, detached, err := readHead(gitRepoPath, reader)
+	if err != nil {
+		return err
+	}
+	oldCommit, from := detached, current
+	if len(detached) != 0 {
+		from = string(detached)
+	} else if repository.Exists(headsPath + current) {
+		oldCommit, err = branchTip(gitRepoPath, current, reader)
+		if err != nil {
+			return err
+		}
+	}
+	oldTree, err := headTree(gitRepoPath, reader, store)
+	if err != nil {
+		return err
+	}
+	err = switchWorkTree(gitRepoPath, workTree, oldTree, treeHash, force, formatter, reader, store)
+	if err != nil {
+		return err
+	}
+	to := branchName
+	if len(branchName) != 0 {
+		err = repository.WriteLocked(
+			repository.HeadPath(gitRepoPath),
+			[]byte(headContent(branchName)),
+			0755,
+		)
+	} else {
+		to = string(newCommit)
+		err = writeDetachedHead(gitRepoPath, newCommit)
+	}
+	if err != nil {
+		return err
+	}
+	//the log lets "@{-1}" find the branch or the commit we came from
+	return appendReflog(
+		gitRepoPath,
+		headRef,
+		oldCommit,
+		newCommit,
+		fmt.Sprintf("%s%s to %s", checkoutMessage, from, to),
+		reader,
+	)
+}
+
+//Move the working tree and the index from one tree to another
+//Only paths that differ between trees are touched, the switch is aborted
+//if any of them has uncommitted changes.
+//With force such changes and changes of all other dirty paths are discarded
+func switchWorkTree(
+	gitRepoPath string,
+	workTree string,
+	oldTree repository.Hash,
+	newTree repository.Hash,
+	force bool,
+	formatter repository.GitFileFormatter,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) error {
+	changes, err := diffTrees(oldTree, newTree, "", store)
+	if err != nil {
+		return err
+	}
+	status, err := GitStatus(gitRepoPath, workTree, formatter, reader, store)
+	if err != nil {
+		return err
+	}
+	dirty := dirtyPaths(status)
+	if !force {
+		err = checkOverwrites(changes, dirty, status.Untracked)
+		if err != nil {
+			return err
+		}
+	}
+	err = applyTreeChanges(workTree, changes, store)
+	if err != nil {
+		return err
+	}
+	blobs, err := flattenTree(newTree, store)
+	if err != nil {
+		return err
+	}
+	//paths that have to be rewritten in the index
+	updated := make(map[string]bool)
+	for _, change := range changes {
+		updated[change.path] = true
+	}
+	if force {
+		for path := range dirty {
+			if updated[path] {
+				continue
+			}
+			updated[path] = true
+			if blob, ok := blobs[path]; ok {
+				err = writeWorkTreeBlob(workTree, blob, store)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return updateIndexPaths(gitRepoPath, workTree, blobs, updated)
+}
+
+//paths with staged or unstaged changes
+func dirtyPaths(status *Status) map[string]bool {
+	dirty := make(map[string]bool)
+	for _, paths := range [][]string{
+		status.StagedNew,
+		status.StagedModified,
+		status.StagedDeleted,
+		status.Modified,
+		status.Deleted,
+		status.Unmerged,
+	} {
+		for _, path := range paths {
+			dirty[path] = true
+		}
+	}
+	return dirty
+}
+
+//make sure that applying changes doesn't lose local modifications
+//or overwrite untracked files
+func checkOverwrites(
+	changes []treeChange,
+	dirty map[string]bool,
+	untracked []string,
+) error {
+	isUntracked := make(map[string]bool)
+	for _, path := range untracked {
+		isUntracked[path] = true
+	}
+	var conflicts []string
+	for _, change := range changes {
+		if dirty[change.path] || isUntracked[change.path] {
+			conflicts = append(conflicts, change.path)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	return errors.New(
+		fmt.Sprintf(
+			"Your local changes to the following files would be overwritten by checkout:\n\t%s\nCommit your changes or use --force to discard them",
+			strings.Join(conflicts, "\n\t"),
+		),
+	)
+}
+
+//set index entries of given paths to blobs from the target tree,
+//paths absent from the target are removed from the index,
+//all other entries are kept as they are
+func updateIndexPaths(
+	gitRepoPath string,
+	workTree string,
+	target map[string]treeEntry,
+	paths map[string]bool,
+) error {
+	indexPath := repository.IndexPath(gitRepoPath)
+	idx, err := index.Read(indexPath)
+	if err != nil {
+		return err
+	}
+	for path := range paths {
+		blob, ok := target[path]
+		if !ok {
+			idx.Remove(path)
+			continue
+		}
+		stat, err := index.StatFile(filepath.Join(workTree, path))
+		if err != nil {
+			return err
+		}
+		idx.Add(index.NewEntry(path, blob.mode, blob.hash, stat))
+	}
+	return idx.Write(indexPath)
+}
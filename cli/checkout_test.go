@@ -0,0 +1,367 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestCheckout(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	checkout := func(branch string, force bool) error {
+		return Checkout(
+			gitDir,
+			dir,
+			branch,
+			force,
+			repository.Reader,
+			store,
+			&formatter,
+		)
+	}
+	err = os.WriteFile(dir+"/shared", []byte("shared"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := commitFiles(t, gitDir, "Base", dir+"/shared")
+	err = writeBranch(gitDir, "feature", "", base, "branch: Created from HEAD", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(dir+"/only/master", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/only/master/file", []byte("master"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/shared", []byte("changed on master"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, gitDir, "Master", dir+"/only", dir+"/shared")
+	//local modification of a file that differs between branches
+	err = os.WriteFile(dir+"/shared", []byte("local"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkout("feature", false)
+	if err == nil {
+		t.Fatal("Checkout should refuse to overwrite local modifications")
+	}
+	content, err := os.ReadFile(dir + "/shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "local" {
+		t.Fatal("Aborted checkout should not touch the working tree")
+	}
+	err = checkout("feature", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = os.ReadFile(dir + "/shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "shared" {
+		t.Fatalf("Wrong content after checkout '%s'", content)
+	}
+	if repository.Exists(dir + "/only") {
+		t.Fatal("Files and directories absent from the branch should be removed")
+	}
+	status, err := GitStatus(gitDir, dir, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Clean() || status.Branch != "feature" {
+		t.Fatalf("Working tree should be clean on 'feature', got\n%s", status)
+	}
+}
+
+func TestCheckout_roundTrip(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	checkout := func(branch string) {
+		err := Checkout(
+			gitDir,
+			dir,
+			branch,
+			false,
+			repository.Reader,
+			store,
+			&formatter,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.WriteFile(dir+"/README", []byte("readme\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := commitFiles(t, gitDir, "Base", dir+"/README")
+	err = writeBranch(gitDir, "base", "", base, "branch: Created from HEAD", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]struct {
+		content string
+		perm    os.FileMode
+	}{
+		"src/main.go":              {"package main\n", 0644},
+		"src/cmd/run.sh":           {"#!/bin/sh\necho run\n", 0755},
+		"src/cmd/deep/nested/data": {"binary\x00data", 0644},
+	}
+	err = os.MkdirAll(dir+"/src/cmd/deep/nested", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, file := range files {
+		err = os.WriteFile(dir+"/"+path, []byte(file.content), file.perm)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.Symlink("cmd/run.sh", dir+"/src/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, gitDir, "Sources", dir+"/src")
+	checkout("base")
+	if repository.Exists(dir + "/src") {
+		t.Fatal("Directory absent from 'base' should be removed")
+	}
+	checkout(repository.DefaultBranch)
+	for path, file := range files {
+		info, err := os.Stat(dir + "/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != file.perm {
+			t.Fatalf("Wrong permissions of '%s', expected %v, got %v", path, file.perm, info.Mode().Perm())
+		}
+		content, err := os.ReadFile(dir + "/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != file.content {
+			t.Fatalf("Wrong content of '%s', expected '%s', got '%s'", path, file.content, content)
+		}
+	}
+	target, err := os.Readlink(dir + "/src/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "cmd/run.sh" {
+		t.Fatalf("Wrong symbolic link target '%s'", target)
+	}
+	status, err := GitStatus(gitDir, dir, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Clean() || len(status.Untracked) != 0 {
+		t.Fatalf("Working tree should be clean after checkout, got\n%s", status)
+	}
+}
+
+func TestCheckout_detachedHead(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	err = os.WriteFile(dir+"/file", []byte("first"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFiles(t, gitDir, "First", dir+"/file")
+	err = os.WriteFile(dir+"/file", []byte("second"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := commitFiles(t, gitDir, "Second", dir+"/file")
+	switchBranch(t, gitDir, string(first)[0:7])
+	content, err := os.ReadFile(dir + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "first" {
+		t.Fatalf("Working tree wasn't switched to the detached commit, got '%s'", content)
+	}
+	status, err := GitStatus(gitDir, dir, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != "" || status.Detached != first || !status.Clean() {
+		t.Fatalf("Expected a clean detached HEAD at %s, got %+v", first, status)
+	}
+	if !strings.HasPrefix(status.String(), "HEAD detached at "+string(first)[0:7]) {
+		t.Fatalf("Status doesn't report the detached HEAD\n%s", status)
+	}
+	//a commit on a detached HEAD doesn't move any branch
+	err = os.WriteFile(dir+"/file", []byte("detached"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	detached := commitFiles(t, gitDir, "Detached", dir+"/file")
+	head, err := headCommit(gitDir, repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != detached {
+		t.Fatalf("Expected HEAD at %s, got %s", detached, head)
+	}
+	master, err := branchTip(gitDir, "master", repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if master != second {
+		t.Fatalf("master was moved by a detached commit to %s", master)
+	}
+	branches, err := ListBranches(gitDir, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 2 || !branches[0].Current || branches[0].Commit != detached || branches[1].Current {
+		t.Fatalf("Expected the detached HEAD to be listed first, got %v", branches)
+	}
+	_, err = Branch(gitDir, repository.Reader)
+	if err == nil {
+		t.Fatal("Detached HEAD was reported as a branch")
+	}
+	previous, err := ResolveBranchName(gitDir, "-", repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "master" {
+		t.Fatalf("Expected to come back to master, got %s", previous)
+	}
+	switchBranch(t, gitDir, previous)
+	status, err = GitStatus(gitDir, dir, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != "master" || len(status.Detached) != 0 {
+		t.Fatalf("Expected to be on master, got %+v", status)
+	}
+	//the detached commit is still reachable through the log of HEAD
+	hash, err := ResolveRevision(gitDir, "@{-1}", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != detached {
+		t.Fatalf("Expected @{-1} to be %s, got %s", detached, hash)
+	}
+}
+
+//a file is replaced by a directory with the same name and back
+func TestCheckout_fileAndDirectorySwap(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	isFile := func() {
+		content, err := os.ReadFile(dir + "/x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "file" {
+			t.Fatalf("Expected x to be a file, got '%s'", content)
+		}
+	}
+	isDirectory := func() {
+		content, err := os.ReadFile(dir + "/x/y")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "nested" {
+			t.Fatalf("Expected x to be a directory, got '%s'", content)
+		}
+	}
+	err = os.WriteFile(dir+"/x", []byte("file"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := commitFiles(t, gitDir, "File", dir+"/x")
+	err = writeBranch(gitDir, "file", "", file, "branch: Created from HEAD", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Remove(gitDir, dir, []string{dir + "/x"}, false, false, false, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(dir+"/x", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/x/y", []byte("nested"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, gitDir, "Directory", dir+"/x")
+	switchBranch(t, gitDir, "file")
+	isFile()
+	switchBranch(t, gitDir, repository.DefaultBranch)
+	isDirectory()
+	//fast-forward merge replaces the file by the directory
+	switchBranch(t, gitDir, "file")
+	result := mergeBranch(t, gitDir, repository.DefaultBranch)
+	if !result.FastForward {
+		t.Fatalf("Merge should fast-forward, got %+v", result)
+	}
+	isDirectory()
+	_, err = Reset(gitDir, dir, string(file), HardReset, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isFile()
+	status, err := GitStatus(gitDir, dir, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Clean() || len(status.Untracked) != 0 {
+		t.Fatalf("Working tree should be clean after the reset, got\n%s", status)
+	}
+}
@@ -1,14 +1,14 @@
 package cli
 
 import (
-	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 
 	"github.com/olekukonko/tablewriter"
+	"github.com/strogiyotec/dzhigit/index"
 	"github.com/strogiyotec/dzhigit/repository"
 )
 
@@ -20,122 +20,39 @@ type treeEntry struct {
 	hash    repository.Hash
 }
 
-//tuple for recursive checkout
-//that contains a hash of a tree and a path that this tree represents
-type checkoutTuple struct {
-	treeHash repository.Hash
-	path     string
-}
-
+//With decorate refs pointing to a commit are appended to its message
 func Log(
 	writer *tablewriter.Table,
 	gitRepoPath string,
-	formatter repository.GitFileFormatter,
+	decorate bool,
 	reader repository.FileReader,
-	objReader repository.ObjectReader,
+	store repository.ObjectStore,
 ) error {
-	headPath := repository.HeadPath(gitRepoPath)
-	content, err := reader(headPath)
-	if err != nil {
-		return errors.New(
-			`There is no branch in this repo,
-            to create one use 'dzhigit update-ref'
-            and then check it out using 'dzhigit checkout' `,
-		)
-	}
-	branch := branchNameFromHead(string(content))
-	headsPath := repository.HeadsPath(gitRepoPath)
-	pathToBranch := headsPath + branch
-	if !repository.Exists(pathToBranch) {
-		return errors.New(
-			fmt.Sprintf(
-				"error branch with name '%s' doesn't exist",
-				branch,
-			),
-		)
-	}
-	commitHashContent, err := reader(pathToBranch)
+	commitHash, err := headCommit(gitRepoPath, reader)
 	if err != nil {
 		return err
 	}
-	commitHash, err := repository.NewHash(string(commitHashContent))
-	if err != nil {
-		return err
+	decorations := make(map[repository.Hash]string)
+	if decorate {
+		decorations, err = refDecorations(gitRepoPath, true, reader, store)
+		if err != nil {
+			return err
+		}
 	}
-	objPath := repository.ObjPath(gitRepoPath)
 	return appendLog(
 		writer,
 		commitHash,
-		objPath,
-		objReader,
-		formatter,
+		decorations,
+		store,
 	)
 }
 
-func appendLog(
-	writer *tablewriter.Table,
-	commitHash repository.Hash,
-	objPath string,
-	objReader repository.ObjectReader,
-	formatter repository.GitFileFormatter,
-) error {
-	commitContent, err := objReader(commitHash.Path(objPath), formatter)
-	if err != nil {
-		return err
-	}
-	commit, err := parseCommit(commitContent.Content)
-	if err != nil {
-		return err
-	}
-	writer.Append(
-		[]string{
-			string(commit.treeHash)[0:5],
-			commit.message,
-			commit.user.String(),
-			commit.time.String(),
-		},
-	)
-	if commit.HasParent() {
-		return appendLog(
-			writer,
-			commit.parentHash,
-			objPath,
-			objReader,
-			formatter,
-		)
-	}
-	return nil
-}
-
-func newTreeEntry(line string) (*treeEntry, error) {
-	parts := strings.Fields(line)
-	mode, err := repository.AsMode(parts[0])
-	if err != nil {
-		return nil, err
-	}
-	objType, err := repository.AsGitObjectType(parts[1])
-	if err != nil {
-		return nil, err
-	}
-	hash, err := repository.NewHash(parts[2])
-	if err != nil {
-		return nil, err
-	}
-	path := parts[3]
-	return &treeEntry{
-		mode:    mode,
-		objType: objType,
-		hash:    hash,
-		path:    path,
-	}, nil
-}
-
-func Branch(
+//hash of a commit HEAD points to, either directly or through the current branch
+func headCommit(
 	gitRepoPath string,
 	reader repository.FileReader,
-) (string, error) {
-	headPath := repository.HeadPath(gitRepoPath)
-	content, err := reader(headPath)
+) (repository.Hash, error) {
+	branch, detached, err := readHead(gitRepoPath, reader)
 	if err != nil {
 		return "", errors.New(
 			`There is no branch in this repo,
@@ -143,114 +60,60 @@ func Branch(
             and then check it out using 'dzhigit checkout' `,
 		)
 	}
-	branch := branchNameFromHead(string(content))
-	return branch, nil
+	if len(detached) != 0 {
+		return detached, nil
+	}
+	return branchTip(gitRepoPath, branch, reader)
 }
 
-func Checkout(
+//commit HEAD points to, empty for a branch without commits
+func headTip(
 	gitRepoPath string,
-	branchName string,
-	objPath string,
 	reader repository.FileReader,
-	objReader repository.ObjectReader,
-	formatter repository.GitFileFormatter,
-) error {
-	headsPath := repository.HeadsPath(gitRepoPath)
-	pathToBranch := headsPath + branchName
-	if !repository.Exists(pathToBranch) {
-		return errors.New(
-			fmt.Sprintf(
-				"error branch with name '%s' doesn't exist",
-				branchName,
-			),
-		)
-	}
-	treeHash, err := treeHashFromBranch(
-		pathToBranch,
-		objPath,
-		formatter,
-		reader,
-		objReader,
-	)
-	if err != nil {
-		return err
-	}
-	err = checkoutRecursively(
-		treeHash,
-		"",
-		reader,
-		objReader,
-		objPath,
-		formatter,
-	)
+) (repository.Hash, error) {
+	branch, detached, err := readHead(gitRepoPath, reader)
 	if err != nil {
-		return err
+		return "", err
 	}
-	head := repository.HeadPath(gitRepoPath)
-	f, err := os.OpenFile(head, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		return err
+	if len(detached) != 0 || !repository.Exists(repository.HeadsPath(gitRepoPath)+branch) {
+		return detached, nil
 	}
-	defer f.Close()
-	_, err = f.Write([]byte(headContent(branchName)))
-	return err
+	return branchTip(gitRepoPath, branch, reader)
 }
 
-func checkoutRecursively(
-	treeHash repository.Hash,
-	rootPath string,
-	reader repository.FileReader,
-	objReader repository.ObjectReader,
-	objPath string,
-	formatter repository.GitFileFormatter,
+func appendLog(
+	writer *tablewriter.Table,
+	commitHash repository.Hash,
+	decorations map[repository.Hash]string,
+	store repository.ObjectStore,
 ) error {
-	//queue of inner trees
-	var queue []checkoutTuple
-	deser, err := objReader(treeHash.Path(objPath), formatter)
+	commitContent, err := store.Get(commitHash)
 	if err != nil {
 		return err
 	}
-	lines := strings.Split(deser.Content, "\n")
-	for _, line := range lines {
-		if len(line) != 0 {
-			treeEntry, err := newTreeEntry(line)
-			if err != nil {
-				return err
-			}
-			//if tree then store in queue and proceed later
-			if treeEntry.objType == repository.TREE {
-				queue = append(
-					queue,
-					checkoutTuple{
-						treeHash: treeEntry.hash,
-						path:     treeEntry.path,
-					},
-				)
-			} else {
-				//else override content right away
-				data, err := reader(treeEntry.hash.Path(objPath))
-				if err != nil {
-					return err
-				}
-				err = os.WriteFile(rootPath+treeEntry.path, data, 0755)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	commit, err := parseCommit(commitContent.Content)
+	if err != nil {
+		return err
+	}
+	message := commit.message
+	if decoration, ok := decorations[commitHash]; ok {
+		message = strings.TrimRight(message, "\n") + decoration
 	}
-	for _, tuple := range queue {
-		err := checkoutRecursively(
-			tuple.treeHash,
-			rootPath+tuple.path+"/",
-			reader,
-			objReader,
-			objPath,
-			formatter,
+	writer.Append(
+		[]string{
+			string(commit.treeHash)[0:5],
+			message,
+			commit.user.String(),
+			commit.time.String(),
+		},
+	)
+	if commit.HasParent() {
+		return appendLog(
+			writer,
+			commit.firstParent(),
+			decorations,
+			store,
 		)
-		if err != nil {
-			return err
-		}
 	}
 	return nil
 }
@@ -259,11 +122,9 @@ func checkoutRecursively(
 func UpdateRef(
 	hash repository.Hash, //commit hash
 	writer io.Writer, //writer to save a hash into commit file
-	objPath string,
-	reader repository.FileReader, //reader to read a hash
-	formatter repository.GitFileFormatter,
+	store repository.ObjectStore,
 ) error {
-	objType, err := repository.TypeByHash(objPath, hash, reader, formatter)
+	objType, err := repository.TypeByHash(store, hash)
 	if err != nil {
 		return err
 	}
@@ -281,163 +142,254 @@ func UpdateRef(
 
 //create a tree object from entries saved in index
 func WriteTree(
-	indexLines []string, //list of entries stored in index file
-	objPath string,
-	gitFormatter repository.GitFileFormatter,
-) (*repository.SerializedGitObject, error) {
-	var indexes []repository.IndexEntry
-	for _, line := range indexLines {
-		index, err := repository.ParseLineToIndex(line)
-		if err != nil {
-			return nil, err
-		}
-		indexes = append(indexes, *index)
+	idx *index.Index,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	conflicts := idx.Conflicts()
+	if len(conflicts) != 0 {
+		return "", errors.New(
+			fmt.Sprintf(
+				"Can't write a tree, these paths have conflicts:\n\t%s",
+				strings.Join(conflicts, "\n\t"),
+			),
+		)
 	}
-	return createTreeEntry(1, indexes, gitFormatter, objPath)
+	return writeTreeFromEntries(idx.Entries(), store)
+}
+
+//create a tree object from index entries
+//an empty index produces an empty tree
+func writeTreeFromEntries(
+	indexes []index.Entry,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	if len(indexes) == 0 {
+		return store.Put(repository.TREE, []byte{})
+	}
+	return createTreeEntry(1, indexes, store)
 }
 
 //cat object by given hash
 func GitCat(
 	hash repository.Hash,
-	fileFormatter repository.GitFileFormatter,
-	objPath string,
-	reader repository.FileReader,
+	store repository.ObjectStore,
 ) (*repository.DeserializedGitObject, error) {
-	if !repository.Exists(hash.Path(objPath)) {
+	if !store.Has(hash) {
 		return nil, errors.New(fmt.Sprintf("File with hash %s doesn't exist", hash))
 	}
-	data, err := reader(hash.Path(objPath))
-	if err != nil {
-		return nil, err
-	}
-	return fileFormatter.Deserialize(data)
+	return store.Get(hash)
 }
 
 //Adds a new entry into an index file
-func UpdateIndex(index repository.IndexEntry, indexPath string) error {
-	f, err := os.OpenFile(
-		indexPath,
-		os.O_CREATE|os.O_RDWR,
-		0644,
-	)
+func UpdateIndex(entry index.Entry, indexPath string) error {
+	idx, err := index.Read(indexPath)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	builder := strings.Builder{}
-	s := bufio.NewScanner(f)
-	indexEmpty := true
-	foundDuplicate := false
-	for s.Scan() {
-		indexEmpty = false
-		text := s.Text()
-		parsedIndex, err := repository.ParseLineToIndex(text)
-		if err != nil {
-			return err
-		}
-		if index.Path() == parsedIndex.Path() {
-			foundDuplicate = true
-			builder.WriteString(index.String() + "\n")
-		} else {
-			builder.WriteString(parsedIndex.String() + "\n")
-		}
-	}
-	if indexEmpty || !foundDuplicate {
-		builder.WriteString(index.String() + "\n")
+	idx.Add(entry)
+	return idx.Write(indexPath)
+}
+
+//point a branch to given commit and record the movement in the branch log
+//old is the tip the caller read, an empty old means the branch doesn't exist yet
+func writeBranch(
+	gitRepoPath string,
+	branch string,
+	old repository.Hash,
+	commitHash repository.Hash,
+	reason string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) error {
+	//the hash is validated before the branch file is touched
+	var content bytes.Buffer
+	err := UpdateRef(commitHash, &content, store)
+	if err != nil {
+		return err
 	}
-	_, err = f.Seek(0, 0)
+	pathToBranch := repository.HeadsPath(gitRepoPath) + branch
+	//nested branch names like "feature/login" are stored in directories which the lock creates,
+	//the branch is only moved if another process didn't move it after it was read
+	err = repository.CompareAndSwap(pathToBranch, []byte(old), content.Bytes(), 0755)
 	if err != nil {
 		return err
 	}
-	_, err = f.Write([]byte(builder.String()))
-	return err
+	return appendReflog(gitRepoPath, branchRef(branch), old, commitHash, reason, reader)
 }
 
-//string that shows how a reference to a tree is stored inside of a tree file
-func treeLine(hash repository.Hash, dir string) string {
-	return fmt.Sprintf("040000 tree %s\t%s\n", hash, dir)
-}
+//Write a tree object for index entries at given depth and all subtrees below it
 func createTreeEntry(
 	level int,
-	indexes []repository.IndexEntry,
-	fileFormatter repository.GitFileFormatter,
-	objPath string,
-) (*repository.SerializedGitObject, error) {
+	indexes []index.Entry,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
 	if len(indexes) == 0 {
-		return nil, nil
+		return "", nil
 	}
-	nextLevels := make(map[string][]repository.IndexEntry)
-	builder := strings.Builder{}
-	for _, index := range indexes {
-		if index.Depth() == level {
-			builder.WriteString(index.BlobString(level - 1))
+	nextLevels := make(map[string][]index.Entry)
+	var entries []repository.TreeEntry
+	for _, entry := range indexes {
+		if entry.Depth() == level {
+			entries = append(entries, entry.TreeEntry(level-1))
 		} else {
-			if val, ok := nextLevels[index.PathParts()[level-1]]; ok {
-				val = append(val, index)
-				nextLevels[index.PathParts()[level-1]] = val
-			} else {
-				var entries []repository.IndexEntry
-				entries = append(entries, index)
-				nextLevels[index.PathParts()[level-1]] = entries
-			}
+			dir := entry.PathParts()[level-1]
+			nextLevels[dir] = append(nextLevels[dir], entry)
 		}
 	}
-	for key, elements := range nextLevels {
-		tree, err := createTreeEntry(level+1, elements, fileFormatter, objPath)
+	for dir, elements := range nextLevels {
+		tree, err := createTreeEntry(level+1, elements, store)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
-		if tree != nil {
-			builder.WriteString(treeLine(tree.Hash, key))
+		if len(tree) != 0 {
+			entries = append(
+				entries,
+				repository.TreeEntry{Mode: repository.DIR, Name: dir, Hash: tree},
+			)
 		}
 	}
-	tree, err := fileFormatter.Serialize(
-		[]byte(builder.String()),
-		repository.TREE,
-	)
+	content, err := repository.EncodeTree(entries)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	err = fileFormatter.Save(tree, objPath)
+	//a tree that already exists is not written again
+	return store.Put(repository.TREE, content)
+}
+
+//Example: "refs: refs/heads/feature/login" -> "feature/login"
+func branchNameFromHead(head string) string {
+	name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(head), "refs:"))
+	name = strings.TrimPrefix(name, "/")
+	return strings.TrimPrefix(name, strings.TrimPrefix(repository.PathToBranch(""), "/"))
+}
+
+//HEAD either refers to a branch or holds a commit hash directly (detached HEAD)
+//returns the name of the branch or the hash of a detached commit
+//a repository with an empty HEAD is on the default branch
+func readHead(
+	gitRepoPath string,
+	reader repository.FileReader,
+) (string, repository.Hash, error) {
+	content, err := reader(repository.HeadPath(gitRepoPath))
 	if err != nil {
-		//if tree already exists then just return it
-		if _, ok := err.(*repository.TreeAlreadyExistError); ok {
-			return tree, nil
-		}
-		return nil, err
+		return "", "", err
+	}
+	head := strings.TrimSpace(string(content))
+	if len(head) != 0 && !strings.HasPrefix(head, "refs:") {
+		hash, err := repository.NewHash(head)
+		return "", hash, err
 	}
-	return tree, nil
+	branch := branchNameFromHead(head)
+	if len(branch) == 0 {
+		return repository.DefaultBranch, "", nil
+	}
+	return branch, "", nil
 }
-func branchNameFromHead(head string) string {
-	parts := strings.Split(head, "/")
-	return parts[len(parts)-1]
+
+//name of the branch HEAD points to, empty if HEAD is detached
+func currentBranch(
+	gitRepoPath string,
+	reader repository.FileReader,
+) (string, error) {
+	branch, _, err := readHead(gitRepoPath, reader)
+	return branch, err
 }
 
-func treeHashFromBranch(
-	pathToBranch string,
-	objPath string,
-	formatter repository.GitFileFormatter,
+//Describe where HEAD is
+//Example: "On branch master", "HEAD detached at 3b0af1d"
+func HeadDescription(
+	gitRepoPath string,
 	reader repository.FileReader,
-	objReader repository.ObjectReader,
-) (repository.Hash, error) {
-	branchContent, err := reader(pathToBranch)
+) (string, error) {
+	branch, detached, err := readHead(gitRepoPath, reader)
 	if err != nil {
 		return "", err
 	}
-	commitHash, err := repository.NewHash(string(branchContent))
+	if len(detached) != 0 {
+		return fmt.Sprintf("HEAD detached at %s", string(detached)[0:7]), nil
+	}
+	return fmt.Sprintf("On branch %s", branch), nil
+}
+
+//Point HEAD to a new commit
+//The current branch is moved, a detached HEAD is rewritten with the hash.
+//old is the commit the caller read from HEAD, HEAD is only moved if it still points to it.
+//The movement is recorded in the log of HEAD and of the current branch
+func moveHead(
+	gitRepoPath string,
+	old repository.Hash,
+	commitHash repository.Hash,
+	reason string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) error {
+	branch, detached, err := readHead(gitRepoPath, reader)
 	if err != nil {
-		return "", err
+		return err
 	}
-	deser, err := objReader(commitHash.Path(objPath), formatter)
+	if len(detached) != 0 {
+		err = repository.CompareAndSwap(
+			repository.HeadPath(gitRepoPath),
+			[]byte(old),
+			[]byte(commitHash),
+			0755,
+		)
+		if err != nil {
+			return err
+		}
+		return appendReflog(gitRepoPath, headRef, old, commitHash, reason, reader)
+	}
+	err = writeBranch(gitRepoPath, branch, old, commitHash, reason, reader, store)
+	if err != nil {
+		return err
+	}
+	//a fresh repository has an empty HEAD
+	err = repository.WriteLocked(
+		repository.HeadPath(gitRepoPath),
+		[]byte(headContent(branch)),
+		0755,
+	)
+	if err != nil {
+		return err
+	}
+	return appendReflog(gitRepoPath, headRef, old, commitHash, reason, reader)
+}
+
+//store a commit hash in HEAD instead of a branch
+func writeDetachedHead(gitRepoPath string, commitHash repository.Hash) error {
+	return repository.WriteLocked(
+		repository.HeadPath(gitRepoPath),
+		[]byte(commitHash),
+		0755,
+	)
+}
+
+//hash of a tree that HEAD points to
+//returns an empty hash if the current branch doesn't have commits yet
+func headTree(
+	gitRepoPath string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	branch, detached, err := readHead(gitRepoPath, reader)
 	if err != nil {
 		return "", err
 	}
-	treeParts := strings.Split(deser.Content, "\n")[0]
-	treeHash, err := repository.NewHash(strings.Split(treeParts, " ")[1])
+	commitHash := detached
+	if len(detached) == 0 {
+		if !repository.Exists(repository.HeadsPath(gitRepoPath) + branch) {
+			return "", nil
+		}
+		commitHash, err = branchTip(gitRepoPath, branch, reader)
+		if err != nil {
+			return "", err
+		}
+	}
+	commit, err := readCommit(commitHash, store)
 	if err != nil {
 		return "", err
 	}
-	return treeHash, nil
+	return commit.treeHash, nil
 }
 
 //content that will be stored in HEAD file
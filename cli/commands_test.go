@@ -2,12 +2,11 @@ package cli
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"strings"
 	"testing"
 
 	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/index"
 	"github.com/strogiyotec/dzhigit/repository"
 )
 
@@ -23,6 +22,7 @@ func TestWriteTree(t *testing.T) {
 	defer os.RemoveAll(dir)
 	formatter := repository.DefaultGitFileFormatter{}
 	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
 	//Create and save a bunch of blobs
 	entries, err := fakes.FakeEntries(formatter)
 	for _, entry := range entries {
@@ -39,8 +39,7 @@ func TestWriteTree(t *testing.T) {
 		t.Fatal(err)
 	}
 	//Save these blobs in index file
-	objPath := repository.ObjPath(gitDir)
-	indexEntries, err := fakes.FakeIndexEntries(entries, files, objPath)
+	indexEntries, err := fakes.FakeIndexEntries(entries, files, store)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -52,17 +51,15 @@ func TestWriteTree(t *testing.T) {
 		}
 	}
 	//Create a tree from this index
-	indexContent, err := ioutil.ReadFile(indexPath)
+	idx, err := index.Read(indexPath)
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		t.Fatal(err)
 	}
-	lines := strings.Split(strings.TrimSpace(string(indexContent)), "\n")
-	tree, err := WriteTree(lines, objPath, &formatter)
+	tree, err := WriteTree(idx, store)
 	if err != nil {
 		t.Fatal(err)
 	}
-	objType, err := repository.TypeByHash(objPath, tree.Hash, repository.Reader, &formatter)
+	objType, err := repository.TypeByHash(store, tree)
 	if err != nil {
 		t.Fatal(err)
 	}
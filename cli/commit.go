@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/strogiyotec/dzhigit/index"
 	"github.com/strogiyotec/dzhigit/repository"
 	"github.com/tcnksm/go-gitconfig"
 )
@@ -22,28 +23,26 @@ type User struct {
 	Email string `json:"email"`
 }
 type Commit struct {
-	treeHash   repository.Hash //hash of a tree object
-	message    string
-	parentHash repository.Hash //hash of a parent commit may be null
-	user       *User
-	time       *Time
+	treeHash     repository.Hash //hash of a tree object
+	message      string
+	parentHashes []repository.Hash //hashes of parent commits, empty for a root commit
+	user         *User
+	time         *Time
 }
 
-//Create a commit object
+//Create a commit object and save it into the store
 //This method does a validation and then delegates
 //an actual commit creation to #createCommitTree
 func CommitTree(
 	commit Commit,
-	path string,
-	fileFormatter repository.GitFileFormatter,
-	reader repository.FileReader,
-) (*repository.SerializedGitObject, error) {
-	tp, err := repository.TypeByHash(path, commit.treeHash, reader, fileFormatter)
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	tp, err := repository.TypeByHash(store, commit.treeHash)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	if tp != repository.TREE {
-		return nil,
+		return "",
 			errors.New(
 				fmt.Sprintf(
 					"Object with given hash '%s' is not a tree object",
@@ -51,28 +50,204 @@ func CommitTree(
 				),
 			)
 	}
-	if commit.HasParent() {
-		tp, err := repository.TypeByHash(path, commit.parentHash, reader, fileFormatter)
+	for _, parentHash := range commit.parentHashes {
+		tp, err := repository.TypeByHash(store, parentHash)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 		if tp != repository.COMMIT {
-			return nil,
+			return "",
 				errors.New(
 					fmt.Sprintf(
 						"Given hash %s is not a commit object",
-						commit.parentHash,
+						parentHash,
 					),
 				)
 		}
 	}
-	return createCommitObject(
-		commit,
-		fileFormatter,
+	return store.Put(repository.COMMIT, createCommitObject(commit))
+}
+
+//Create a commit from the index and move the current branch to it
+//The parent is the current branch tip, a first commit has no parent.
+//With a detached HEAD the new commit is stored in HEAD itself
+//With amend the tip commit is replaced by a new one with tip's parents
+//A commit made after a conflicting merge gets the merged commit as a second parent
+func CommitIndex(
+	gitRepoPath string,
+	message string,
+	allowEmpty bool,
+	amend bool,
+	user *User,
+	time *Time,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	tip, err := headTip(gitRepoPath, reader)
+	if err != nil {
+		return "", err
+	}
+	return commitIndex(gitRepoPath, tip, message, allowEmpty, amend, user, time, reader, store)
+}
+
+//Create a commit on top of the tip HEAD was read at
+//HEAD isn't moved if it was moved by another process after that
+func commitIndex(
+	gitRepoPath string,
+	tip repository.Hash,
+	message string,
+	allowEmpty bool,
+	amend bool,
+	user *User,
+	time *Time,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	mergeHead, err := readMergeHead(gitRepoPath, reader)
+	if err != nil {
+		return "", err
+	}
+	var parents []repository.Hash
+	if len(tip) != 0 {
+		parents = append(parents, tip)
+	}
+	if len(mergeHead) != 0 {
+		parents = append(parents, mergeHead)
+	}
+	if amend {
+		if len(mergeHead) != 0 {
+			return "", errors.New("You are in the middle of a merge -- cannot amend")
+		}
+		if len(tip) == 0 {
+			return "", errors.New("You have nothing to amend")
+		}
+		tipCommit, err := readCommit(tip, store)
+		if err != nil {
+			return "", err
+		}
+		//an amended merge keeps all its parents
+		parents = tipCommit.parentHashes
+		if len(message) == 0 {
+			message = strings.TrimRight(tipCommit.message, "\n")
+		}
+	}
+	if len(message) == 0 {
+		return "", errors.New("Aborting commit due to empty commit message")
+	}
+	idx, err := index.Read(repository.IndexPath(gitRepoPath))
+	if err != nil {
+		return "", err
+	}
+	conflicts := idx.Conflicts()
+	if len(conflicts) != 0 {
+		return "", errors.New(
+			fmt.Sprintf(
+				"Committing is not possible because you have unmerged files:\n\t%s",
+				strings.Join(conflicts, "\n\t"),
+			),
+		)
+	}
+	entries := idx.Entries()
+	tree, err := writeTreeFromEntries(entries, store)
+	if err != nil {
+		return "", err
+	}
+	//a merge commit is never empty because it joins two histories
+	if !allowEmpty && len(parents) < 2 {
+		var parentTree repository.Hash
+		if len(parents) != 0 {
+			parentCommit, err := readCommit(parents[0], store)
+			if err != nil {
+				return "", err
+			}
+			parentTree = parentCommit.treeHash
+		}
+		if tree == parentTree || (len(parents) == 0 && len(entries) == 0) {
+			return "", errors.New(
+				"nothing to commit, use '--allow-empty' to create an empty commit",
+			)
+		}
+	}
+	commit := NewMergeCommit(tree, message, parents, user, time)
+	commitHash, err := CommitTree(*commit, store)
+	if err != nil {
+		return "", err
+	}
+	reason := "commit"
+	switch {
+	case amend:
+		reason = "commit (amend)"
+	case len(mergeHead) != 0:
+		reason = "commit (merge)"
+	case len(parents) == 0:
+		reason = "commit (initial)"
+	}
+	err = moveHead(
+		gitRepoPath,
+		tip,
+		commitHash,
+		fmt.Sprintf("%s: %s", reason, message),
+		reader,
+		store,
 	)
+	if err != nil {
+		return "", err
+	}
+	if len(mergeHead) != 0 {
+		err = os.Remove(repository.MergeHeadPath(gitRepoPath))
+		if err != nil {
+			return "", err
+		}
+	}
+	return commitHash, nil
 }
+
+//hash of a commit that is being merged, empty if there is no merge in progress
+func readMergeHead(
+	gitRepoPath string,
+	reader repository.FileReader,
+) (repository.Hash, error) {
+	mergeHeadPath := repository.MergeHeadPath(gitRepoPath)
+	if !repository.Exists(mergeHeadPath) {
+		return "", nil
+	}
+	content, err := reader(mergeHeadPath)
+	if err != nil {
+		return "", err
+	}
+	return repository.NewHash(strings.TrimSpace(string(content)))
+}
+
+//read and parse a commit object by given hash
+func readCommit(
+	hash repository.Hash,
+	store repository.ObjectStore,
+) (*Commit, error) {
+	deser, err := store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if deser.ObjType != repository.COMMIT {
+		return nil, errors.New(
+			fmt.Sprintf(
+				"Object with given hash '%s' is not a commit object",
+				hash,
+			),
+		)
+	}
+	return parseCommit(deser.Content)
+}
+
 func (c *Commit) HasParent() bool {
-	return len(c.parentHash) != 0
+	return len(c.parentHashes) != 0
+}
+
+//hash of the first parent, empty for a root commit
+func (c *Commit) firstParent() repository.Hash {
+	if c.HasParent() {
+		return c.parentHashes[0]
+	}
+	return ""
 }
 
 func (u *User) String() string {
@@ -91,22 +266,36 @@ func NewCommit(
 	parent repository.Hash,
 	user *User,
 	time *Time,
+) *Commit {
+	var parents []repository.Hash
+	if len(parent) != 0 {
+		parents = append(parents, parent)
+	}
+	return NewMergeCommit(hash, message, parents, user, time)
+}
+
+//Create a commit with any amount of parents
+func NewMergeCommit(
+	hash repository.Hash,
+	message string,
+	parents []repository.Hash,
+	user *User,
+	time *Time,
 ) *Commit {
 	return &Commit{
-		treeHash:   hash,
-		message:    message,
-		parentHash: parent,
-		user:       user,
-		time:       time,
+		treeHash:     hash,
+		message:      message,
+		parentHashes: parents,
+		user:         user,
+		time:         time,
 	}
 }
 func CurrentTime() *Time {
 	now := time.Now()
-	zone, _ := now.Zone()
-	seconds := now.Unix()
 	return &Time{
-		zone:        zone,
-		unixSeconds: seconds,
+		//git expects an offset like "+0300" rather than a zone name
+		zone:        now.Format("-0700"),
+		unixSeconds: now.Unix(),
 	}
 }
 
@@ -140,20 +329,17 @@ func DefaultGitUserAsJson() ([]byte, error) {
 // | Commit format line by line |
 // +----------------------------+
 // | tree hash                  |
-// | parent hash                |
+// | parent hash (zero or more) |
 // | author                     |
-// | comitter                   |
+// | committer                  |
 // | empty line                 |
 // | commit message             |
 // +----------------------------+
-func createCommitObject(
-	commit Commit,
-	formatter repository.GitFileFormatter,
-) (*repository.SerializedGitObject, error) {
+func createCommitObject(commit Commit) []byte {
 	builder := strings.Builder{}
 	builder.WriteString(fmt.Sprintf("tree %s\n", commit.treeHash))
-	if commit.HasParent() {
-		builder.WriteString(fmt.Sprintf("parent %s\n", commit.parentHash))
+	for _, parentHash := range commit.parentHashes {
+		builder.WriteString(fmt.Sprintf("parent %s\n", parentHash))
 	}
 	builder.WriteString(
 		fmt.Sprintf(
@@ -166,7 +352,7 @@ func createCommitObject(
 	)
 	builder.WriteString(
 		fmt.Sprintf(
-			"comitter %s <%s> %d %s\n",
+			"committer %s <%s> %d %s\n",
 			commit.user.Name,
 			commit.user.Email,
 			commit.time.unixSeconds,
@@ -175,41 +361,49 @@ func createCommitObject(
 	)
 	builder.WriteString("\n")
 	builder.WriteString(fmt.Sprintf("%s\n", commit.message))
-	return formatter.Serialize([]byte(builder.String()), repository.COMMIT)
+	return []byte(builder.String())
 }
 
+//Commits written by older versions have a misspelled "comitter" header,
+//the committer is ignored anyway so both of them are accepted
 func parseCommit(content string) (*Commit, error) {
-	parts := strings.Split(content, "\n")
-	commit := &Commit{}
-	treeHash, err := repository.NewHash(strings.Fields(parts[0])[1])
-	if err != nil {
-		return nil, err
+	parts := strings.SplitN(content, "\n\n", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("Invalid commit object, there is no message")
 	}
-	commit.treeHash = treeHash
-	nextIndex := 1
-	if strings.Contains(parts[1], "parent") {
-		parentHash, err := repository.NewHash(strings.Fields(parts[1])[1])
-		if err != nil {
-			return nil, err
+	commit := &Commit{message: parts[1]}
+	for _, line := range strings.Split(parts[0], "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		key, value := fields[0], fields[1]
+		switch key {
+		case "tree":
+			treeHash, err := repository.NewHash(value)
+			if err != nil {
+				return nil, err
+			}
+			commit.treeHash = treeHash
+		case "parent":
+			parentHash, err := repository.NewHash(value)
+			if err != nil {
+				return nil, err
+			}
+			commit.parentHashes = append(commit.parentHashes, parentHash)
+		case "author":
+			user, time, err := parseIdentity(value)
+			if err != nil {
+				return nil, err
+			}
+			commit.user, commit.time = user, time
 		}
-		commit.parentHash = parentHash
-		nextIndex++
-	}
-	authorParts := strings.Fields(parts[nextIndex])
-	commit.user = &User{
-		Name:  authorParts[1],
-		Email: authorParts[2],
 	}
-	timeUnix, err := strconv.ParseInt(authorParts[3], 10, 64)
-	if err != nil {
-		return nil, err
+	if len(commit.treeHash) == 0 {
+		return nil, errors.New("Invalid commit object, there is no tree header")
 	}
-	commit.time = &Time{
-		unixSeconds: timeUnix,
-		zone:        authorParts[4],
+	if commit.user == nil {
+		return nil, errors.New("Invalid commit object, there is no author header")
 	}
-	//skip committer and empty line
-	nextIndex += 3
-	commit.message = strings.Join(parts[nextIndex:], "\n")
 	return commit, nil
 }
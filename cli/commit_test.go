@@ -2,9 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/index"
 	"github.com/strogiyotec/dzhigit/repository"
 )
 
@@ -40,18 +44,18 @@ func Test_createCommitTree(t *testing.T) {
 		unixSeconds: time.Now().Unix(),
 	}
 	commit := Commit{
-		treeHash:   repository.Hash(treeHash),
-		message:    "New Commit",
-		parentHash: repository.Hash(parentHash),
-		user:       user,
-		time:       time,
+		treeHash:     repository.Hash(treeHash),
+		message:      "New Commit",
+		parentHashes: []repository.Hash{repository.Hash(parentHash)},
+		user:         user,
+		time:         time,
 	}
-	formatter := repository.DefaultGitFileFormatter{}
-	commitObj, err := createCommitObject(commit, &formatter)
+	store := repository.NewMemoryStore()
+	commitHash, err := store.Put(repository.COMMIT, createCommitObject(commit))
 	if err != nil {
 		t.Fatal(err)
 	}
-	deser, err := formatter.Deserialize(commitObj.Content)
+	deser, err := store.Get(commitHash)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -112,3 +116,174 @@ func TestUser_String(t *testing.T) {
 		t.Fatal("Wrong user's string representation")
 	}
 }
+
+func TestCommitIndex(t *testing.T) {
+	userJson, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(userJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	user := &User{Name: "Almas", Email: "almas337519@gmail.com"}
+	commit := func(message string, allowEmpty bool, amend bool) (repository.Hash, error) {
+		return CommitIndex(
+			gitDir,
+			message,
+			allowEmpty,
+			amend,
+			user,
+			CurrentTime(),
+			repository.Reader,
+			store,
+		)
+	}
+	_, err = commit("Empty", false, false)
+	if err == nil {
+		t.Fatal("Commit of an empty index should fail")
+	}
+	err = os.WriteFile(dir+"/file", []byte("content"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Add(gitDir, dir, []string{dir + "/file"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := commit("First", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = commit("Nothing changed", false, false)
+	if err == nil {
+		t.Fatal("Commit without changes should fail")
+	}
+	second, err := commit("Second", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondCommit, err := readCommit(second, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secondCommit.firstParent() != first {
+		t.Fatalf("Wrong parent, '%s' expected, got '%s'", first, secondCommit.firstParent())
+	}
+	amended, err := commit("Amended", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amendedCommit, err := readCommit(amended, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amendedCommit.firstParent() != first {
+		t.Fatalf("Amended commit should replace the tip, parent '%s' expected, got '%s'", first, amendedCommit.firstParent())
+	}
+	tip, err := os.ReadFile(repository.HeadsPath(gitDir) + repository.DefaultBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repository.Hash(tip) != amended {
+		t.Fatalf("Branch should point to '%s', got '%s'", amended, tip)
+	}
+	//another process moved the branch after the tip was read
+	_, err = commitIndex(gitDir, first, "Stale", true, false, user, CurrentTime(), repository.Reader, store)
+	if err == nil {
+		t.Fatal("A commit on top of a stale tip should not move the branch")
+	}
+	tip, err = os.ReadFile(repository.HeadsPath(gitDir) + repository.DefaultBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repository.Hash(tip) != amended {
+		t.Fatalf("Branch was moved from '%s' to '%s'", amended, tip)
+	}
+	//an index with conflict stages can't be committed
+	idx, err := index.Read(repository.IndexPath(gitDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := idx.Get("file")
+	idx.Add(entry.WithStage(index.Ours), entry.WithStage(index.Theirs))
+	err = idx.Write(repository.IndexPath(gitDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = commit("Conflict", true, false)
+	if err == nil || !strings.Contains(err.Error(), "unmerged files") {
+		t.Fatalf("Commit with unmerged files should fail, got %v", err)
+	}
+}
+
+//stage given files and commit them on the current branch
+func commitFiles(t *testing.T, gitDir string, message string, files ...string) repository.Hash {
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	if len(files) != 0 {
+		_, err := Add(gitDir, repository.WorkTreePath(gitDir), files, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := CommitIndex(
+		gitDir,
+		message,
+		true,
+		false,
+		&User{Name: "Almas", Email: "almas337519@gmail.com"},
+		CurrentTime(),
+		repository.Reader,
+		store,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func Test_parseCommitWithTwoParents(t *testing.T) {
+	treeHash, err := repository.GenerateHash([]byte("Tree hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := repository.GenerateHash([]byte("First parent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repository.GenerateHash([]byte("Second parent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := NewMergeCommit(
+		repository.Hash(treeHash),
+		"Merge",
+		[]repository.Hash{repository.Hash(first), repository.Hash(second)},
+		&User{Name: "strogiyotec", Email: "almas337519@gmail.com"},
+		CurrentTime(),
+	)
+	store := repository.NewMemoryStore()
+	commitHash, err := store.Put(repository.COMMIT, createCommitObject(*commit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deser, err := store.Get(commitHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseCommit(deser.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.parentHashes) != 2 ||
+		parsed.parentHashes[0] != repository.Hash(first) ||
+		parsed.parentHashes[1] != repository.Hash(second) {
+		t.Fatalf("Wrong parents %v", parsed.parentHashes)
+	}
+	if parsed.message != "Merge\n" {
+		t.Fatalf("Wrong commit message '%s'", parsed.message)
+	}
+}
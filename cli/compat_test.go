@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//objects below were created by git for the same input:
+//a tree with hello.txt, lib-a, lib.go, lib/inner.txt and an executable run.sh,
+//a commit of that tree and an annotated tag of the commit
+const (
+	gitTree   = repository.Hash("46ba25ff127bb2c57449f39e13a32c83c42f7cb1")
+	gitCommit = repository.Hash("d245c7518bd3878a38f48ea2d4a8bf1ff90a305f")
+	gitTag    = repository.Hash("39174436d33b2b488bd2390fc66ccb269f959bfd")
+)
+
+func compatUser() (*User, *Time) {
+	return &User{Name: "Almas Abdrazak", Email: "almas337519@gmail.com"},
+		&Time{unixSeconds: 1630023095, zone: "-0700"}
+}
+
+func TestWriteTree_gitCompatible(t *testing.T) {
+	store := repository.NewMemoryStore()
+	blobs := map[string]string{
+		"hello.txt":     "hello\n",
+		"run.sh":        "#!/bin/sh\necho hi\n",
+		"lib-a":         "x",
+		"lib.go":        "x",
+		"lib/inner.txt": "x",
+	}
+	var entries []index.Entry
+	for path, content := range blobs {
+		blob, err := store.Put(repository.BLOB, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mode := repository.FILE
+		if path == "run.sh" {
+			mode = repository.EXECUTABLE
+		}
+		entries = append(entries, index.NewEntry(path, mode, blob, index.Stat{}))
+	}
+	tree, err := writeTreeFromEntries(entries, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree != gitTree {
+		t.Fatalf("git tree '%s' expected, got '%s'", gitTree, tree)
+	}
+}
+
+func TestCreateCommitObject_gitCompatible(t *testing.T) {
+	user, time := compatUser()
+	store := repository.NewMemoryStore()
+	commit, err := store.Put(
+		repository.COMMIT,
+		createCommitObject(
+			Commit{treeHash: gitTree, message: "Initial commit", user: user, time: time},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit != gitCommit {
+		t.Fatalf("git commit '%s' expected, got '%s'", gitCommit, commit)
+	}
+	second, err := store.Put(
+		repository.COMMIT,
+		createCommitObject(
+			Commit{
+				treeHash:     "d0fd48f8a028d89b630a5c6bb9f94b12ff4c3853",
+				parentHashes: []repository.Hash{gitCommit},
+				message:      "Second commit",
+				user:         user,
+				time:         time,
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := repository.Hash("8011d636a9cd332730ac279d01fcd245e0282069")
+	if second != expected {
+		t.Fatalf("git commit '%s' expected, got '%s'", expected, second)
+	}
+}
+
+func TestCreateTagObject_gitCompatible(t *testing.T) {
+	user, time := compatUser()
+	store := repository.NewMemoryStore()
+	tag, err := store.Put(
+		repository.TAG,
+		createTagObject(
+			Tag{
+				object:  gitCommit,
+				objType: repository.COMMIT,
+				name:    "v1.0",
+				message: "Release",
+				user:    user,
+				time:    time,
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != gitTag {
+		t.Fatalf("git tag '%s' expected, got '%s'", gitTag, tag)
+	}
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/pack"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//amounts and sizes of objects in a repository, sizes are in bytes
+type ObjectCount struct {
+	Count         int //loose objects
+	Size          int64
+	InPack        int //objects in all packs
+	Packs         int
+	SizePack      int64 //packs and their indexes
+	PrunePackable int   //loose objects that are packed as well
+	Garbage       int   //files that are neither objects nor packs
+	SizeGarbage   int64
+}
+
+//Count loose and packed objects of an object repository
+//Files of the repository are inspected directly, so unknown files are counted as garbage
+func CountObjects(store *repository.FileStore) (*ObjectCount, error) {
+	count := &ObjectCount{}
+	packs, err := store.Packs.Packs()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		count.Packs++
+		count.InPack += p.Index().Len()
+	}
+	objPath := store.Loose.Dir()
+	entries, err := ioutil.ReadDir(objPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir() && name == strings.TrimSuffix(repository.Packs, "/"):
+			err = count.countPackDir(store.Packs.Dir())
+		case entry.IsDir() && name == "info":
+			//git keeps alternates and packs lists there
+		case entry.IsDir() && len(name) == 2:
+			err = count.countLooseDir(objPath, name, packs)
+		default:
+			count.addGarbage(entry)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return count, nil
+}
+
+//"<count> objects, <size> kilobytes" like "git count-objects"
+func (c *ObjectCount) String() string {
+	return fmt.Sprintf("%d objects, %d kilobytes", c.Count, kilobytes(c.Size))
+}
+
+//All counters, one "<name>: <value>" line each like "git count-objects -v"
+func (c *ObjectCount) Verbose() string {
+	return fmt.Sprintf(
+		"count: %d\nsize: %d\nin-pack: %d\npacks: %d\nsize-pack: %d\nprune-packable: %d\ngarbage: %d\nsize-garbage: %d",
+		c.Count,
+		kilobytes(c.Size),
+		c.InPack,
+		c.Packs,
+		kilobytes(c.SizePack),
+		c.PrunePackable,
+		c.Garbage,
+		kilobytes(c.SizeGarbage),
+	)
+}
+
+func (c *ObjectCount) countLooseDir(objPath string, dir string, packs []*pack.Pack) error {
+	files, err := ioutil.ReadDir(objPath + dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		hash, err := repository.NewHash(dir + file.Name())
+		if err != nil || file.IsDir() {
+			c.addGarbage(file)
+			continue
+		}
+		c.Count++
+		c.Size += file.Size()
+		for _, p := range packs {
+			if p.Has(string(hash)) {
+				c.PrunePackable++
+				break
+			}
+		}
+	}
+	return nil
+}
+
+//a pack without an index can't be read so it's garbage, as well as leftovers of interrupted writes
+func (c *ObjectCount) countPackDir(packDir string) error {
+	files, err := ioutil.ReadDir(packDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := file.Name()
+		paired := false
+		switch {
+		case strings.HasSuffix(name, pack.PackExt):
+			paired = repository.Exists(pack.IndexPath(packDir + name))
+		case strings.HasSuffix(name, pack.IndexExt):
+			paired = repository.Exists(pack.PackPath(packDir + name))
+		}
+		if paired && strings.HasPrefix(name, "pack-") {
+			c.SizePack += file.Size()
+		} else {
+			c.addGarbage(file)
+		}
+	}
+	return nil
+}
+
+func (c *ObjectCount) addGarbage(file os.FileInfo) {
+	c.Garbage++
+	if !file.IsDir() {
+		c.SizeGarbage += file.Size()
+	}
+}
+
+func kilobytes(size int64) int64 {
+	return (size + 1023) / 1024
+}
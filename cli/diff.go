@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/strogiyotec/dzhigit/diff"
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//hash printed for a side of a diff that doesn't exist
+const nullHash = "0000000"
+
+//Print changes between the index and the working tree
+func DiffWorkTree(
+	writer io.Writer,
+	gitRepoPath string,
+	workTree string,
+	context int,
+	formatter repository.GitFileFormatter,
+	store repository.ObjectStore,
+) error {
+	idx, err := index.Read(repository.IndexPath(gitRepoPath))
+	if err != nil {
+		return err
+	}
+	entries := idx.Entries()
+	indexTime, err := indexModificationTime(gitRepoPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		//versions of a conflicting path are resolved by hand first
+		if entry.Stage() != index.Merged {
+			continue
+		}
+		file := filepath.Join(workTree, entry.Path())
+		old := indexTreeEntry(entry)
+		if !workTreeExists(file) {
+			oldContent, err := loadBlob(entry.Hash(), store)
+			if err != nil {
+				return err
+			}
+			err = writeFilePatch(writer, entry.Path(), &old, nil, oldContent, "", context)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		changed, err := workTreeChanged(file, entry, indexTime, formatter)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+		oldContent, err := loadBlob(entry.Hash(), store)
+		if err != nil {
+			return err
+		}
+		content, info, err := readWorkTreeFile(file)
+		if err != nil {
+			return err
+		}
+		blob, err := formatter.Serialize(content, repository.BLOB)
+		if err != nil {
+			return err
+		}
+		new := treeEntry{
+			mode:    repository.FileMode(info),
+			objType: repository.BLOB,
+			path:    entry.Path(),
+			hash:    blob.Hash,
+		}
+		err = writeFilePatch(writer, entry.Path(), &old, &new, oldContent, string(content), context)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Print changes between HEAD and the index
+func DiffCached(
+	writer io.Writer,
+	gitRepoPath string,
+	context int,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) error {
+	treeHash, err := headTree(gitRepoPath, reader, store)
+	if err != nil {
+		return err
+	}
+	headEntries, err := flattenTree(treeHash, store)
+	if err != nil {
+		return err
+	}
+	idx, err := index.Read(repository.IndexPath(gitRepoPath))
+	if err != nil {
+		return err
+	}
+	entries := idx.Entries()
+	indexEntries := make(map[string]treeEntry)
+	for _, entry := range entries {
+		if entry.Stage() == index.Merged {
+			indexEntries[entry.Path()] = indexTreeEntry(entry)
+		}
+	}
+	return diffBlobMaps(
+		writer,
+		headEntries,
+		indexEntries,
+		context,
+		store,
+	)
+}
+
+//Print changes between trees of two commits
+func DiffCommits(
+	writer io.Writer,
+	from repository.Hash,
+	to repository.Hash,
+	context int,
+	store repository.ObjectStore,
+) error {
+	var trees []map[string]treeEntry
+	for _, hash := range []repository.Hash{from, to} {
+		commit, err := readCommit(hash, store)
+		if err != nil {
+			return err
+		}
+		tree, err := flattenTree(commit.treeHash, store)
+		if err != nil {
+			return err
+		}
+		trees = append(trees, tree)
+	}
+	return diffBlobMaps(
+		writer,
+		trees[0],
+		trees[1],
+		context,
+		store,
+	)
+}
+
+//print a patch for every path which blob or mode differs
+func diffBlobMaps(
+	writer io.Writer,
+	oldEntries map[string]treeEntry,
+	newEntries map[string]treeEntry,
+	context int,
+	store repository.ObjectStore,
+) error {
+	paths := make(map[string]bool)
+	for path := range oldEntries {
+		paths[path] = true
+	}
+	for path := range newEntries {
+		paths[path] = true
+	}
+	var sorted []string
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+	for _, path := range sorted {
+		var old, new *treeEntry
+		var oldContent, newContent string
+		if entry, ok := oldEntries[path]; ok {
+			old = &entry
+		}
+		if entry, ok := newEntries[path]; ok {
+			new = &entry
+		}
+		if old != nil && new != nil && old.hash == new.hash && old.mode == new.mode {
+			continue
+		}
+		var err error
+		if old != nil {
+			oldContent, err = loadBlob(old.hash, store)
+			if err != nil {
+				return err
+			}
+		}
+		if new != nil {
+			newContent, err = loadBlob(new.hash, store)
+			if err != nil {
+				return err
+			}
+		}
+		err = writeFilePatch(writer, path, old, new, oldContent, newContent, context)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//print a git style patch for a single file
+//nil entry means that the file doesn't exist on this side
+func writeFilePatch(
+	writer io.Writer,
+	path string,
+	old *treeEntry,
+	new *treeEntry,
+	oldContent string,
+	newContent string,
+	context int,
+) error {
+	oldName := "a/" + path
+	newName := "b/" + path
+	oldHash, newHash := nullHash, nullHash
+	_, err := fmt.Fprintf(writer, "diff --git %s %s\n", oldName, newName)
+	if err != nil {
+		return err
+	}
+	switch {
+	case old == nil:
+		_, err = fmt.Fprintf(writer, "new file mode %s\n", new.mode)
+		oldName = "/dev/null"
+	case new == nil:
+		_, err = fmt.Fprintf(writer, "deleted file mode %s\n", old.mode)
+		newName = "/dev/null"
+	case old.mode != new.mode:
+		_, err = fmt.Fprintf(writer, "old mode %s\nnew mode %s\n", old.mode, new.mode)
+	}
+	if err != nil {
+		return err
+	}
+	//only the mode was changed
+	if old != nil && new != nil && old.hash == new.hash {
+		return nil
+	}
+	if old != nil {
+		oldHash = string(old.hash)[0:7]
+	}
+	if new != nil {
+		newHash = string(new.hash)[0:7]
+	}
+	_, err = fmt.Fprintf(writer, "index %s..%s\n", oldHash, newHash)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(
+		writer,
+		diff.Unified(oldName, newName, oldContent, newContent, context),
+	)
+	return err
+}
+
+//read the content of a blob
+func loadBlob(
+	hash repository.Hash,
+	store repository.ObjectStore,
+) (string, error) {
+	deser, err := store.Get(hash)
+	if err != nil {
+		return "", err
+	}
+	return deser.Content, nil
+}
+
+//represent an index entry the same way as a blob in a tree
+func indexTreeEntry(entry index.Entry) treeEntry {
+	return treeEntry{
+		mode:    entry.Mode(),
+		objType: repository.BLOB,
+		path:    entry.Path(),
+		hash:    entry.Hash(),
+	}
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestDiffCachedAndWorkTree(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	err = os.WriteFile(dir+"/file", []byte("first\nsecond\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Add(gitDir, dir, []string{dir + "/file"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cached bytes.Buffer
+	err = DiffCached(&cached, gitDir, 3, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cached.String(), "new file mode 100644\n") ||
+		!strings.Contains(cached.String(), "@@ -0,0 +1,2 @@\n+first\n+second\n") {
+		t.Fatalf("Wrong diff between HEAD and index\n%s", cached.String())
+	}
+	err = os.WriteFile(dir+"/file", []byte("first\nchanged\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var workTree bytes.Buffer
+	err = DiffWorkTree(&workTree, gitDir, dir, 3, &formatter, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(workTree.String(), "@@ -1,2 +1,2 @@\n first\n-second\n+changed\n") {
+		t.Fatalf("Wrong diff between index and working tree\n%s", workTree.String())
+	}
+}
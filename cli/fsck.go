@@ -0,0 +1,409 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/pack"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//bits of the exit code of fsck, a code combines bits of all found problems
+const (
+	FsckCorrupt    = 1 //an object can't be read or parsed, or its content doesn't match its hash
+	FsckBrokenLink = 2 //an object refers to a missing object or to an object of a wrong type
+	FsckBadRef     = 4 //a ref or an index entry points to a missing object or to an object of a wrong type
+)
+
+//kinds of problems, they are part of the json report so they never change
+const (
+	fsckCorrupt   = "corrupt"
+	fsckMissing   = "missing"
+	fsckWrongType = "wrong-type"
+	fsckBadRef    = "bad-ref"
+	fsckBadIndex  = "bad-index"
+	fsckDangling  = "dangling" //doesn't fail fsck, such objects are deleted by prune
+)
+
+//single problem found by fsck
+type FsckProblem struct {
+	Kind    string                   `json:"kind"`
+	Hash    repository.Hash          `json:"hash,omitempty"`
+	Type    repository.GitObjectType `json:"type,omitempty"`
+	Source  string                   `json:"source,omitempty"` //object, ref, index entry or pack with the problem
+	Message string                   `json:"message,omitempty"`
+}
+
+type FsckReport struct {
+	Objects  int           `json:"objects"` //amount of checked objects
+	Problems []FsckProblem `json:"problems"`
+	ExitCode int           `json:"exitCode"`
+}
+
+//state of a check, objects that were read successfully are kept to check links between them
+type fsck struct {
+	gitRepoPath string
+	store       *repository.FileStore
+	formatter   repository.GitFileFormatter
+	reader      repository.FileReader
+	objects     map[repository.Hash]*repository.DeserializedGitObject
+	corrupt     map[repository.Hash]bool
+	referenced  map[repository.Hash]bool
+	report      *FsckReport
+}
+
+//Verify integrity of the object store
+//Every loose and packed object is rehashed, trees, commits and tags are parsed
+//and objects they refer to must exist and have the right type.
+//Refs and index entries must point to existing objects of the right type,
+//objects that nothing refers to are reported as dangling.
+//Files of the store are read directly, so a corrupt loose object doesn't hide its packed copy
+func Fsck(
+	gitRepoPath string,
+	formatter repository.GitFileFormatter,
+	reader repository.FileReader,
+	store *repository.FileStore,
+) (*FsckReport, error) {
+	f := &fsck{
+		gitRepoPath: gitRepoPath,
+		store:       store,
+		formatter:   formatter,
+		reader:      reader,
+		objects:     make(map[repository.Hash]*repository.DeserializedGitObject),
+		corrupt:     make(map[repository.Hash]bool),
+		referenced:  make(map[repository.Hash]bool),
+		report:      &FsckReport{Problems: []FsckProblem{}},
+	}
+	err := f.readLoose()
+	if err != nil {
+		return nil, err
+	}
+	err = f.readPacked()
+	if err != nil {
+		return nil, err
+	}
+	hashes := f.sortedObjects()
+	for _, hash := range hashes {
+		f.checkLinks(hash, f.objects[hash])
+	}
+	err = f.checkRefs()
+	if err != nil {
+		return nil, err
+	}
+	err = f.checkIndex()
+	if err != nil {
+		return nil, err
+	}
+	logged, err := reflogHashes(gitRepoPath, reader)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range logged {
+		f.referenced[hash] = true
+	}
+	for _, hash := range hashes {
+		if !f.referenced[hash] {
+			f.add(FsckProblem{Kind: fsckDangling, Hash: hash, Type: f.objects[hash].ObjType})
+		}
+	}
+	f.report.Objects = len(f.objects) + len(f.corrupt)
+	sort.SliceStable(f.report.Problems, func(i, j int) bool {
+		a, b := f.report.Problems[i], f.report.Problems[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Hash != b.Hash {
+			return a.Hash < b.Hash
+		}
+		return a.Source < b.Source
+	})
+	return f.report, nil
+}
+
+//Human readable problem like "missing blob <hash>: referred by tree <hash>"
+func (p FsckProblem) String() string {
+	var description string
+	switch p.Kind {
+	case fsckCorrupt:
+		description = fmt.Sprintf("corrupt object %s", p.Hash)
+		if len(p.Hash) == 0 {
+			description = fmt.Sprintf("corrupt pack %s", p.Source)
+		}
+	case fsckMissing:
+		description = fmt.Sprintf("missing %s %s", p.Type, p.Hash)
+	case fsckWrongType:
+		description = fmt.Sprintf("wrong type of %s", p.Hash)
+	case fsckBadRef:
+		description = fmt.Sprintf("bad ref %s", p.Source)
+	case fsckBadIndex:
+		description = fmt.Sprintf("bad index entry %s", p.Source)
+	default:
+		description = fmt.Sprintf("%s %s %s", p.Kind, p.Type, p.Hash)
+	}
+	if len(p.Message) != 0 {
+		description += ": " + p.Message
+	}
+	return description
+}
+
+//Report in the json format for scripts
+func (r *FsckReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func (f *fsck) add(problem FsckProblem) {
+	f.report.Problems = append(f.report.Problems, problem)
+	switch problem.Kind {
+	case fsckCorrupt:
+		f.report.ExitCode |= FsckCorrupt
+	case fsckMissing, fsckWrongType:
+		f.report.ExitCode |= FsckBrokenLink
+	case fsckBadRef, fsckBadIndex:
+		f.report.ExitCode |= FsckBadRef
+	}
+}
+
+//read and rehash every loose object
+func (f *fsck) readLoose() error {
+	loose, err := repository.Hashes(f.store.Loose)
+	if err != nil {
+		return err
+	}
+	for _, hash := range loose {
+		data, err := f.reader(f.store.Loose.Path(hash))
+		if err != nil {
+			return err
+		}
+		deser, err := f.formatter.Deserialize(data)
+		if err != nil {
+			f.markCorrupt(hash, err.Error())
+			continue
+		}
+		ser, err := f.formatter.Serialize([]byte(deser.Content), deser.ObjType)
+		if err != nil {
+			return err
+		}
+		if ser.Hash != hash {
+			f.markCorrupt(hash, fmt.Sprintf("its content has hash %s", ser.Hash))
+			continue
+		}
+		f.objects[hash] = deser
+	}
+	return nil
+}
+
+//verify every pack and read objects that don't have a loose copy
+func (f *fsck) readPacked() error {
+	packs, err := f.store.Packs.Packs()
+	if err != nil {
+		return err
+	}
+	for _, p := range packs {
+		if _, err := pack.Verify(p.Path()); err != nil {
+			f.add(FsckProblem{Kind: fsckCorrupt, Source: p.Path(), Message: err.Error()})
+		}
+		for _, entry := range p.Index().Entries() {
+			hash := repository.Hash(entry.Hash)
+			if _, ok := f.objects[hash]; ok {
+				continue
+			}
+			object, err := p.Get(entry.Hash)
+			if err != nil {
+				f.markCorrupt(hash, err.Error())
+				continue
+			}
+			if actual := pack.HashOf(object.Type, object.Data); actual != entry.Hash {
+				f.markCorrupt(hash, fmt.Sprintf("its content has hash %s", actual))
+				continue
+			}
+			objType, err := repository.AsGitObjectType(object.Type.String())
+			if err != nil {
+				f.markCorrupt(hash, err.Error())
+				continue
+			}
+			delete(f.corrupt, hash)
+			f.objects[hash] = &repository.DeserializedGitObject{
+				ObjType: objType,
+				Content: string(object.Data),
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fsck) markCorrupt(hash repository.Hash, message string) {
+	if f.corrupt[hash] {
+		return
+	}
+	f.corrupt[hash] = true
+	f.add(FsckProblem{Kind: fsckCorrupt, Hash: hash, Message: message})
+}
+
+//parse an object and check objects it refers to
+func (f *fsck) checkLinks(hash repository.Hash, deser *repository.DeserializedGitObject) {
+	source := fmt.Sprintf("%s %s", deser.ObjType, hash)
+	switch deser.ObjType {
+	case repository.COMMIT:
+		commit, err := parseCommit(deser.Content)
+		if err != nil {
+			f.markCorrupt(hash, err.Error())
+			return
+		}
+		f.link(source, commit.treeHash, repository.TREE)
+		for _, parent := range commit.parentHashes {
+			f.link(source, parent, repository.COMMIT)
+		}
+	case repository.TREE:
+		entries, err := repository.DecodeTree(deser.Content)
+		if err != nil {
+			f.markCorrupt(hash, err.Error())
+			return
+		}
+		for _, entry := range entries {
+			f.link(source, entry.Hash, entry.ObjType())
+		}
+	case repository.TAG:
+		tag, err := parseTag(deser.Content)
+		if err != nil {
+			f.markCorrupt(hash, err.Error())
+			return
+		}
+		f.link(source, tag.object, tag.objType)
+	}
+}
+
+//check that an object exists and has the expected type, an empty type matches any object
+func (f *fsck) link(source string, hash repository.Hash, expected repository.GitObjectType) {
+	f.referenced[hash] = true
+	if f.corrupt[hash] {
+		return
+	}
+	deser, ok := f.objects[hash]
+	if !ok {
+		f.add(FsckProblem{
+			Kind:    fsckMissing,
+			Hash:    hash,
+			Type:    expected,
+			Source:  source,
+			Message: "referred by " + source,
+		})
+		return
+	}
+	if len(expected) != 0 && deser.ObjType != expected {
+		f.add(FsckProblem{
+			Kind:    fsckWrongType,
+			Hash:    hash,
+			Type:    deser.ObjType,
+			Source:  source,
+			Message: fmt.Sprintf("%s expected by %s, got %s", expected, source, deser.ObjType),
+		})
+	}
+}
+
+//branches, HEAD and MERGE_HEAD point to commits, tags point to any object
+func (f *fsck) checkRefs() error {
+	files, err := refFiles(f.gitRepoPath)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name, err := filepath.Rel(f.gitRepoPath, file)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		content, err := f.reader(file)
+		if err != nil {
+			return err
+		}
+		value := strings.TrimSpace(string(content))
+		//an empty HEAD is on the default branch, a branch without commits doesn't have a file yet
+		if file == repository.HeadPath(f.gitRepoPath) && (len(value) == 0 || strings.HasPrefix(value, "refs:")) {
+			branch := branchNameFromHead(value)
+			if len(branch) == 0 {
+				continue
+			}
+			if repository.ValidateRefName(branch) != nil {
+				f.add(FsckProblem{Kind: fsckBadRef, Source: name, Message: fmt.Sprintf("'%s' is not a valid branch", branch)})
+			}
+			continue
+		}
+		hash, err := repository.NewHash(value)
+		if err != nil || !isHash(value) {
+			f.add(FsckProblem{Kind: fsckBadRef, Source: name, Message: fmt.Sprintf("'%s' is not a hash", value)})
+			continue
+		}
+		var expected repository.GitObjectType = repository.COMMIT
+		if strings.HasPrefix(name, strings.TrimPrefix(repository.Refs+repository.Tags, "/")) {
+			expected = ""
+		}
+		f.checkPointer(fsckBadRef, name, hash, expected)
+	}
+	return nil
+}
+
+//every index entry points to a blob
+func (f *fsck) checkIndex() error {
+	idx, err := index.Read(repository.IndexPath(f.gitRepoPath))
+	if err != nil {
+		f.add(FsckProblem{Kind: fsckBadIndex, Source: repository.IndexPath(f.gitRepoPath), Message: err.Error()})
+		return nil
+	}
+	for _, entry := range idx.Entries() {
+		f.checkPointer(fsckBadIndex, entry.Path(), entry.Hash(), repository.BLOB)
+	}
+	return nil
+}
+
+//check a ref or an index entry
+func (f *fsck) checkPointer(
+	kind string,
+	source string,
+	hash repository.Hash,
+	expected repository.GitObjectType,
+) {
+	f.referenced[hash] = true
+	if f.corrupt[hash] {
+		f.add(FsckProblem{Kind: kind, Hash: hash, Source: source, Message: "points to a corrupt object"})
+		return
+	}
+	deser, ok := f.objects[hash]
+	if !ok {
+		f.add(FsckProblem{Kind: kind, Hash: hash, Source: source, Message: fmt.Sprintf("points to a missing object %s", hash)})
+		return
+	}
+	if len(expected) != 0 && deser.ObjType != expected {
+		f.add(FsckProblem{
+			Kind:    kind,
+			Hash:    hash,
+			Type:    deser.ObjType,
+			Source:  source,
+			Message: fmt.Sprintf("points to %s %s, %s expected", deser.ObjType, hash, expected),
+		})
+	}
+}
+
+func (f *fsck) sortedObjects() []repository.Hash {
+	hashes := make([]repository.Hash, 0, len(f.objects))
+	for hash := range f.objects {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+	return hashes
+}
+
+//a hash written by hand may have upper case or non hex characters
+func isHash(value string) bool {
+	for _, c := range value {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestFsck(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	err = os.WriteFile(dir+"/file", []byte("content\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, gitDir, "First", dir+"/file")
+	fsck := func() *FsckReport {
+		report, err := Fsck(gitDir, &formatter, repository.Reader, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	report := fsck()
+	if report.ExitCode != 0 || len(report.Problems) != 0 || report.Objects != 3 {
+		t.Fatalf("Repository should be consistent, got %+v", report)
+	}
+	dangling, err := store.Put(repository.BLOB, []byte("dangling"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = fsck()
+	if report.ExitCode != 0 || len(report.Problems) != 1 ||
+		report.Problems[0].Kind != "dangling" || report.Problems[0].Hash != dangling {
+		t.Fatalf("Dangling blob should be reported without failing, got %+v", report)
+	}
+	//the blob of the file is replaced with a different one, so the tree and the index point to a corrupt object
+	blob, err := formatter.Serialize([]byte("content\n"), repository.BLOB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Rename(store.Loose.Path(dangling), store.Loose.Path(blob.Hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(repository.HeadsPath(gitDir)+"broken", []byte(blob.Hash), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = fsck()
+	if report.ExitCode != FsckCorrupt|FsckBadRef {
+		t.Fatalf("Corrupt object and bad refs should be reported, got %+v", report)
+	}
+	kinds := make(map[string]int)
+	for _, problem := range report.Problems {
+		kinds[problem.Kind]++
+	}
+	if kinds["corrupt"] != 1 || kinds["bad-ref"] != 1 || kinds["bad-index"] != 1 {
+		t.Fatalf("Wrong problems %+v", report.Problems)
+	}
+	os.Remove(store.Loose.Path(blob.Hash))
+	os.Remove(repository.HeadsPath(gitDir) + "broken")
+	report = fsck()
+	if report.ExitCode != FsckBrokenLink|FsckBadRef {
+		t.Fatalf("Missing blob should be reported, got %+v", report)
+	}
+	content, err := report.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed FsckReport
+	err = json.Unmarshal(content, &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ExitCode != report.ExitCode || parsed.Problems[0].Kind != "bad-index" ||
+		parsed.Problems[1].Kind != "missing" || parsed.Problems[1].Type != repository.BLOB {
+		t.Fatalf("Wrong json report %s", content)
+	}
+}
+
+func TestFsck_malformedObject(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	err = os.WriteFile(dir+"/file", []byte("content\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, gitDir, "First", dir+"/file")
+	blob, err := formatter.Serialize([]byte("content\n"), repository.BLOB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the blob has neither a space nor a NUL byte in its header
+	var garbage bytes.Buffer
+	writer := zlib.NewWriter(&garbage)
+	writer.Write([]byte("blobgarbage"))
+	writer.Close()
+	os.Remove(store.Loose.Path(blob.Hash))
+	err = os.WriteFile(store.Loose.Path(blob.Hash), garbage.Bytes(), 0444)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Fsck(gitDir, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := false
+	for _, problem := range report.Problems {
+		corrupt = corrupt || problem.Kind == "corrupt" && problem.Hash == blob.Hash
+	}
+	if report.ExitCode&FsckCorrupt == 0 || !corrupt {
+		t.Fatalf("Malformed object should be reported as corrupt, got %+v", report)
+	}
+}
@@ -0,0 +1,334 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/pack"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//result of a garbage collection
+type GCResult struct {
+	Pack   string            //path of the new pack, empty if there is nothing to pack
+	Packed int               //amount of objects in the new pack
+	Pruned []repository.Hash //unreachable objects that were deleted
+}
+
+//Find all objects that can be reached from refs, HEAD, MERGE_HEAD, reflogs and the index
+//A missing object that a ref or the index needs is an error,
+//reflogs may point to objects that were already pruned so such entries are skipped
+func ReachableObjects(
+	gitRepoPath string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (map[repository.Hash]repository.GitObjectType, error) {
+	files, err := refFiles(gitRepoPath)
+	if err != nil {
+		return nil, err
+	}
+	var roots []repository.Hash
+	for _, refFile := range files {
+		content, err := reader(refFile)
+		if err != nil {
+			return nil, err
+		}
+		//HEAD that refers to a branch is not a hash
+		if hash, err := repository.NewHash(strings.TrimSpace(string(content))); err == nil {
+			roots = append(roots, hash)
+		}
+	}
+	idx, err := index.Read(repository.IndexPath(gitRepoPath))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range idx.Entries() {
+		roots = append(roots, entry.Hash())
+	}
+	logged, err := reflogHashes(gitRepoPath, reader)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range logged {
+		if store.Has(hash) {
+			roots = append(roots, hash)
+		}
+	}
+	reachable := make(map[repository.Hash]repository.GitObjectType)
+	for len(roots) != 0 {
+		hash := roots[len(roots)-1]
+		roots = roots[:len(roots)-1]
+		if _, ok := reachable[hash]; ok {
+			continue
+		}
+		if !store.Has(hash) {
+			return nil, errors.New(fmt.Sprintf("Reachable object %s is missing", hash))
+		}
+		deser, err := store.Get(hash)
+		if err != nil {
+			return nil, err
+		}
+		reachable[hash] = deser.ObjType
+		switch deser.ObjType {
+		case repository.COMMIT:
+			commit, err := parseCommit(deser.Content)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Commit %s: %s", hash, err.Error()))
+			}
+			roots = append(roots, commit.treeHash)
+			roots = append(roots, commit.parentHashes...)
+		case repository.TREE:
+			entries, err := repository.DecodeTree(deser.Content)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Tree %s: %s", hash, err.Error()))
+			}
+			for _, entry := range entries {
+				roots = append(roots, entry.Hash)
+			}
+		case repository.TAG:
+			tag, err := parseTag(deser.Content)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Tag %s: %s", hash, err.Error()))
+			}
+			roots = append(roots, tag.object)
+		}
+	}
+	return reachable, nil
+}
+
+//existing files of HEAD, MERGE_HEAD and all refs
+func refFiles(gitRepoPath string) ([]string, error) {
+	var files []string
+	for _, file := range []string{repository.HeadPath(gitRepoPath), repository.MergeHeadPath(gitRepoPath)} {
+		if repository.Exists(file) {
+			files = append(files, file)
+		}
+	}
+	err := filepath.Walk(
+		gitRepoPath+repository.Refs,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			//lock of a ref that is being written by another process
+			if repository.IsLockFile(path) {
+				return nil
+			}
+			files = append(files, path)
+			return nil
+		},
+	)
+	return files, err
+}
+
+//old and new hashes of all entries of all reflogs, invalid entries are skipped
+func reflogHashes(gitRepoPath string, reader repository.FileReader) ([]repository.Hash, error) {
+	logsPath := gitRepoPath + repository.Logs
+	if !repository.Exists(logsPath) {
+		return nil, nil
+	}
+	var hashes []repository.Hash
+	err := filepath.Walk(
+		logsPath,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			content, err := reader(path)
+			if err != nil {
+				return err
+			}
+			for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+				entry, err := parseReflogEntry(line)
+				if err != nil {
+					continue
+				}
+				if len(entry.Old) != 0 {
+					hashes = append(hashes, entry.Old)
+				}
+				hashes = append(hashes, entry.New)
+			}
+			return nil
+		},
+	)
+	return hashes, err
+}
+
+//Pack all reachable objects into a single pack and delete unreachable ones
+//Old packs are replaced by the new one, their unreachable objects are kept as loose ones
+//if the pack was modified after expire, so only objects older than expire are deleted
+func GC(
+	gitRepoPath string,
+	expire time.Time,
+	reader repository.FileReader,
+	store *repository.FileStore,
+) (*GCResult, error) {
+	reachable, err := ReachableObjects(gitRepoPath, reader, store)
+	if err != nil {
+		return nil, err
+	}
+	oldPacks, err := store.Packs.Packs()
+	if err != nil {
+		return nil, err
+	}
+	result := &GCResult{}
+	if len(reachable) != 0 {
+		objects := make([]pack.Object, 0, len(reachable))
+		for _, hash := range sortedHashes(reachable) {
+			object, err := packObject(store, hash)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, object)
+		}
+		result.Pack, err = pack.Create(store.Packs.Dir(), objects, pack.DefaultOptions)
+		if err != nil {
+			return nil, err
+		}
+		result.Packed = len(objects)
+	}
+	for _, old := range oldPacks {
+		//packing the same objects again gives a pack with the same name
+		if old.Path() == result.Pack {
+			continue
+		}
+		err = loosenUnreachable(old, reachable, expire, store.Loose)
+		if err != nil {
+			return nil, err
+		}
+		err = os.Remove(pack.IndexPath(old.Path()))
+		if err != nil {
+			return nil, err
+		}
+		err = os.Remove(old.Path())
+		if err != nil {
+			return nil, err
+		}
+	}
+	loose, err := repository.Hashes(store.Loose)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range loose {
+		if _, ok := reachable[hash]; ok {
+			err = store.Loose.Remove(hash)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	result.Pruned, err = pruneLoose(store.Loose, reachable, expire, false)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//Delete loose objects that are not reachable and were modified before expire
+//dryRun - only report objects that would be deleted
+func Prune(
+	gitRepoPath string,
+	expire time.Time,
+	dryRun bool,
+	reader repository.FileReader,
+	store *repository.FileStore,
+) ([]repository.Hash, error) {
+	reachable, err := ReachableObjects(gitRepoPath, reader, store)
+	if err != nil {
+		return nil, err
+	}
+	return pruneLoose(store.Loose, reachable, expire, dryRun)
+}
+
+func pruneLoose(
+	loose *repository.LooseStore,
+	reachable map[repository.Hash]repository.GitObjectType,
+	expire time.Time,
+	dryRun bool,
+) ([]repository.Hash, error) {
+	hashes, err := repository.Hashes(loose)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []repository.Hash
+	for _, hash := range hashes {
+		if _, ok := reachable[hash]; ok {
+			continue
+		}
+		//a corrupt object is pruned as well, so only the file is inspected
+		info, err := os.Stat(loose.Path(hash))
+		if err != nil {
+			return nil, err
+		}
+		if !info.ModTime().Before(expire) {
+			continue
+		}
+		pruned = append(pruned, hash)
+		if dryRun {
+			continue
+		}
+		//the directory is only removed when it's empty
+		err = loose.Remove(hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
+
+//write unreachable objects of a pack that is going to be deleted as loose ones,
+//they get the modification time of the pack so the grace period doesn't start again
+func loosenUnreachable(
+	p *pack.Pack,
+	reachable map[repository.Hash]repository.GitObjectType,
+	expire time.Time,
+	loose *repository.LooseStore,
+) error {
+	info, err := os.Stat(p.Path())
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Before(expire) {
+		return nil
+	}
+	for _, entry := range p.Index().Entries() {
+		hash := repository.Hash(entry.Hash)
+		if _, ok := reachable[hash]; ok || loose.Has(hash) {
+			continue
+		}
+		object, err := p.Get(entry.Hash)
+		if err != nil {
+			return err
+		}
+		objType, err := repository.AsGitObjectType(object.Type.String())
+		if err != nil {
+			return err
+		}
+		_, err = loose.Put(objType, object.Data)
+		if err != nil {
+			return err
+		}
+		err = os.Chtimes(loose.Path(hash), info.ModTime(), info.ModTime())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedHashes(objects map[repository.Hash]repository.GitObjectType) []repository.Hash {
+	hashes := make([]repository.Hash, 0, len(objects))
+	for hash := range objects {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+	return hashes
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/pack"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestGC(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	for i := 0; i < 3; i++ {
+		err = os.WriteFile(dir+"/file", []byte(fmt.Sprintf("version %d\n", i)), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commitFiles(t, gitDir, fmt.Sprintf("Commit %d", i), dir+"/file")
+	}
+	saveBlob := func(content string, modified time.Time) repository.Hash {
+		blob, err := store.Put(repository.BLOB, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(store.Loose.Path(blob), modified, modified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blob
+	}
+	now := time.Now()
+	old := saveBlob("old dangling blob", now.Add(-time.Hour))
+	recent := saveBlob("recent dangling blob", now)
+	expire := now.Add(-time.Minute)
+	pruned, err := Prune(gitDir, expire, true, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 1 || pruned[0] != old || !store.Loose.Has(old) {
+		t.Fatalf("Dry run should only report the old blob, got %v", pruned)
+	}
+	//a pack with dangling objects is replaced, the recent one survives as a loose object
+	_, err = PackObjects(store, nil, pack.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Loose.Remove(recent)
+	result, err := GC(gitDir, expire, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Pruned) != 1 || result.Pruned[0] != old {
+		t.Fatalf("Only the old blob should be pruned, got %v", result.Pruned)
+	}
+	count, err := CountObjects(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//three commits, trees and blobs are packed
+	if count.Count != 1 || count.Packs != 1 || count.InPack != 9 || count.Garbage != 0 {
+		t.Fatalf("Wrong counts after gc\n%s", count.Verbose())
+	}
+	if !store.Loose.Has(recent) {
+		t.Fatal("Recent unreachable object should be kept")
+	}
+	head, err := ResolveRevision(gitDir, "HEAD~2", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readCommit(head, store); err != nil {
+		t.Fatal(err)
+	}
+	//everything unreachable is deleted without a grace period
+	result, err = GC(gitDir, time.Now().Add(time.Second), repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err = CountObjects(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.Count != 0 || count.Packs != 1 || count.InPack != 9 {
+		t.Fatalf("Wrong counts after the second gc\n%s", count.Verbose())
+	}
+}
@@ -1,7 +1,37 @@
 package cli
 
+import (
+	"os"
+	"time"
+
+	"github.com/alecthomas/kong"
+)
+
+//Directory given by -C
+//The process moves into it as soon as the flag is parsed,
+//so relative paths of arguments that follow are resolved against it like in git
+type Chdir string
+
+func (c *Chdir) Decode(ctx *kong.DecodeContext) error {
+	var dir string
+	err := ctx.Scan.PopValueInto("dir", &dir)
+	if err != nil {
+		return err
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		return err
+	}
+	*c = Chdir(dir)
+	return nil
+}
+
 var Git struct {
+	Chdir Chdir `help:"Run as if dzhigit was started in given directory" short:"C" placeholder:"DIR"`
+
 	Init struct {
+		Bare bool   `help:"Create a repository without a working tree, files of the repository are created in the directory itself"`
+		Dir  string `arg optional name:"dir" help:"Directory of the repository, the current one by default" type:"path"`
 	} `cmd help:"Init empty repository"`
 	Add struct {
 		Files []string `arg name:"files" help:"files to add" type:"path"`
@@ -12,12 +42,12 @@ var Git struct {
 		File  string `arg name:"file" help:"path to file to generate hash from" type :"path"`
 	} `cmd help:"Creates a hash of a given file"`
 	CatFile struct {
-		Hash string `arg name:"hash" help:"object hash"`
+		Hash string `arg name:"hash" help:"object hash or revision, e.g. HEAD~2^{tree}"`
 	} `cmd help:"Print the content of a blob by hash"`
 	UpdateIndex struct {
 		Hash string `arg name:"hash" help:"hash" `
 		File string `arg name:"file" help:"path to file to save in index" `
-		Mode string `arg name:"mode" help:"file mode" enum:"100644,100755" default"100644"`
+		Mode string `arg name:"mode" help:"file mode" enum:"100644,100755,120000" default"100644"`
 	} `cmd help:"Update index"`
 	LsTree struct {
 	} `cmd help:"Print index content"`
@@ -25,18 +55,101 @@ var Git struct {
 	} `cmd help:"Create a tree object from index file"`
 	CommitTree struct {
 		Message string `help:"Commit message" short:"m" required:""`
-		Parent  string ` help:"hash of a parent commit" short:"p" default:"" `
-		Hash    string `arg name:"hash" help:"hash of a tree object" `
+		Parent  string ` help:"hash or revision of a parent commit" short:"p" default:"" `
+		Hash    string `arg name:"hash" help:"hash or revision of a tree object" `
 	} `cmd help:"Create a commit object"`
+	Commit struct {
+		Message    string `help:"Commit message" short:"m" default:""`
+		AllowEmpty bool   `help:"Allow a commit with the same tree as its parent"`
+		Amend      bool   `help:"Replace the tip of the current branch"`
+	} `cmd help:"Record staged changes and move the current branch"`
 	UpdateRef struct {
 		Name string `help:"Name of a branch" arg name:"name"`
-		Hash string `help:"Hash of a tree object" arg name:"hash"`
+		Hash string `help:"Hash or revision of a commit" arg name:"hash"`
 	} `cmd help:"Create a new branch"`
 	Checkout struct {
-		Branch string `arg name:"branch" help:"Name of a branch to checkout"`
+		Force  bool   `help:"Discard local changes" short:"f"`
+		Branch string `arg name:"branch" help:"Name of a branch to checkout, - or @{-n} for a previous one"`
 	} `cmd help:"Checkout a given branch"`
 	Branch struct {
-	} `cmd help:"Print current branch"`
+		Delete      bool   `help:"Delete a branch merged into HEAD" short:"d"`
+		ForceDelete bool   `help:"Delete a branch even if it's not merged" short:"D"`
+		Move        bool   `help:"Rename a branch" short:"m"`
+		Verbose     bool   `help:"Show the tip commit of each branch" short:"v"`
+		Name        string `arg optional name:"name" help:"Name of a branch"`
+		Start       string `arg optional name:"start" help:"Commit or branch the new branch points to, new name with -m"`
+	} `cmd help:"List, create, rename or delete branches"`
 	Log struct {
+		Patch    bool `help:"Show the diff of each commit" short:"p"`
+		Unified  int  `help:"Number of context lines" short:"U" default:"3"`
+		Decorate bool `help:"Show branches and tags pointing to commits"`
 	} `cmd help:"Print the list of commits with messages"`
+	Diff struct {
+		Cached  bool     `help:"Compare the index with HEAD"`
+		Unified int      `help:"Number of context lines" short:"U" default:"3"`
+		Commits []string `arg optional name:"commits" help:"two commits to compare"`
+	} `cmd help:"Show changes between working tree, index and commits"`
+	Merge struct {
+		Branch string `arg name:"branch" help:"Name of a branch to merge into the current one"`
+	} `cmd help:"Join the history of given branch with the current one"`
+	Rm struct {
+		Cached    bool     `help:"Only remove from the index"`
+		Recursive bool     `help:"Allow recursive removal of directories" short:"r"`
+		Force     bool     `help:"Remove files with uncommitted changes" short:"f"`
+		Paths     []string `arg name:"paths" help:"files to remove" type:"path"`
+	} `cmd help:"Remove files from the index and the working tree"`
+	Tag struct {
+		Annotate bool   `help:"Create an annotated tag, requires a message" short:"a"`
+		Message  string `help:"Message of an annotated tag" short:"m" default:""`
+		Delete   bool   `help:"Delete a tag" short:"d"`
+		Force    bool   `help:"Replace an existing tag" short:"f"`
+		Show     bool   `help:"Print a tag and the commit it points to"`
+		Name     string `arg optional name:"name" help:"Name of a tag"`
+		Object   string `arg optional name:"object" help:"Revision to tag, HEAD by default"`
+	} `cmd help:"Create, list, delete or show tags"`
+	Reset struct {
+		Soft     bool     `help:"Only move the current branch" xor:"mode"`
+		Mixed    bool     `help:"Move the branch and reset the index (default)" xor:"mode"`
+		Hard     bool     `help:"Move the branch, reset the index and the working tree" xor:"mode"`
+		Revision string   `arg optional name:"revision" help:"Commit to reset to, HEAD by default"`
+		Paths    []string `arg optional name:"paths" help:"Only restore index entries of these paths, given after --" type:"path"`
+	} `cmd help:"Move the current branch, reset the index and the working tree"`
+	Reflog struct {
+		Ref string `arg optional name:"ref" help:"Branch name or HEAD, HEAD by default"`
+	} `cmd help:"Show the history of a ref movements"`
+	Status struct {
+		Porcelain bool `help:"Machine readable output"`
+	} `cmd help:"Show staged, modified and untracked files"`
+	MigrateObjects struct {
+	} `cmd help:"Rewrite text trees and commits of older versions in the format of git"`
+	CheckIgnore struct {
+		Verbose bool     `help:"Show the pattern that matched each path" short:"v"`
+		Paths   []string `arg name:"paths" help:"paths to check, a trailing / marks a directory"`
+	} `cmd help:"Check if paths are excluded by .dzhigitignore files"`
+	PackObjects struct {
+		Window   int      `help:"Amount of previous objects tried as a delta base" default:"10"`
+		Depth    int      `help:"Maximum length of a chain of deltas" default:"50"`
+		RefDelta bool     `help:"Refer to delta bases by hash instead of offset"`
+		Objects  []string `arg optional name:"objects" help:"hashes or revisions of objects to pack, all loose objects by default"`
+	} `cmd help:"Write objects into a pack with its index"`
+	IndexPack struct {
+		Pack string `arg name:"pack" help:"path to a .pack file" type:"path"`
+	} `cmd help:"Build an index for a pack"`
+	VerifyPack struct {
+		Verbose bool     `help:"Show every object and lengths of delta chains" short:"v"`
+		Packs   []string `arg name:"packs" help:"paths to .pack or .idx files" type:"path"`
+	} `cmd help:"Check that packs and their indexes are valid"`
+	Gc struct {
+		Expire time.Duration `help:"Grace period, unreachable objects modified within it are kept" default:"336h"`
+	} `cmd help:"Pack reachable objects and delete unreachable ones"`
+	Prune struct {
+		DryRun bool          `help:"Only print objects that would be deleted" short:"n"`
+		Expire time.Duration `help:"Grace period, unreachable objects modified within it are kept" default:"336h"`
+	} `cmd help:"Delete unreachable loose objects"`
+	CountObjects struct {
+		Verbose bool `help:"Show packed objects and garbage as well" short:"v"`
+	} `cmd help:"Count objects and their disk usage"`
+	Fsck struct {
+		JSON bool `help:"Print the report in json" name:"json"`
+	} `cmd help:"Verify objects, links between them, refs and the index. Exit code: 0 - ok, bits 1 - corrupt objects, 2 - broken links, 4 - bad refs or index entries, 128 - fsck failed"`
 }
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/ignore"
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//a path checked against ignore files
+type IgnoreMatch struct {
+	Path    string
+	Pattern *ignore.Pattern //nil if no pattern matches the path
+}
+
+//Check if the path is ignored, a negated pattern re-includes it
+func (m IgnoreMatch) Ignored() bool {
+	return m.Pattern != nil && !m.Pattern.Negated
+}
+
+//Explain which pattern matched the path
+//Format: "<source>:<line>:<pattern>\t<path>", the source is relative to the working tree
+func (m IgnoreMatch) Verbose(workTree string) string {
+	if m.Pattern == nil {
+		return fmt.Sprintf("::\t%s", m.Path)
+	}
+	source, err := filepath.Rel(workTree, m.Pattern.Source)
+	if err != nil {
+		source = m.Pattern.Source
+	}
+	return fmt.Sprintf(
+		"%s:%d:%s\t%s",
+		filepath.ToSlash(source),
+		m.Pattern.Line,
+		m.Pattern.Text,
+		m.Path,
+	)
+}
+
+//Check given paths against .dzhigitignore files and the exclude file of the repository
+//Returns a match for every path that matches a pattern,
+//tracked files are never ignored so they are skipped
+func CheckIgnore(gitRepoPath string, workTree string, paths []string) ([]IgnoreMatch, error) {
+	matcher, err := newIgnoreMatcher(gitRepoPath, workTree)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := index.Read(repository.IndexPath(gitRepoPath))
+	if err != nil {
+		return nil, err
+	}
+	entries := idx.Entries()
+	tracked := make(map[string]bool)
+	for _, entry := range entries {
+		tracked[entry.Path()] = true
+	}
+	var matches []IgnoreMatch
+	for _, path := range paths {
+		relative, err := relativePath(workTree, path)
+		if err != nil {
+			return nil, err
+		}
+		if tracked[relative] {
+			continue
+		}
+		pattern, err := matcher.Match(relative, isWorkTreeDir(path))
+		if err != nil {
+			return nil, err
+		}
+		if pattern != nil {
+			matches = append(matches, IgnoreMatch{Path: path, Pattern: pattern})
+		}
+	}
+	return matches, nil
+}
+
+//matcher of ignore patterns of a working tree
+func newIgnoreMatcher(gitRepoPath string, workTree string) (*ignore.Matcher, error) {
+	return ignore.NewMatcher(
+		workTree,
+		repository.ExcludePath(gitRepoPath),
+	)
+}
+
+//check if a file or a directory of the working tree is ignored
+func pathIgnored(matcher *ignore.Matcher, workTree string, path string) (bool, error) {
+	relative, err := relativePath(workTree, path)
+	if err != nil {
+		return false, err
+	}
+	return matcher.Ignored(relative, isWorkTreeDir(path))
+}
+
+//a path with a trailing slash is a directory even if it doesn't exist
+func isWorkTreeDir(path string) bool {
+	if strings.HasSuffix(path, "/") {
+		return true
+	}
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir()
+}
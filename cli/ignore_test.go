@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestCheckIgnore(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	files := map[string]string{
+		dir + "/.dzhigitignore":     "*.log\nbuild/\n",
+		dir + "/src/.dzhigitignore": "!keep.log\n",
+		dir + "/src/main.go":        "package main",
+		dir + "/src/debug.log":      "debug",
+		dir + "/src/keep.log":       "keep",
+		dir + "/build/out":          "out",
+		dir + "/tracked.log":        "tracked",
+	}
+	for path, content := range files {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.MkdirAll(gitDir+"/info", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(repository.ExcludePath(gitDir), []byte("*.go\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Add(gitDir, dir, []string{dir + "/src/debug.log"}, store)
+	if err == nil {
+		t.Fatal("Adding an ignored file should fail")
+	}
+	//ignore files don't affect files that are already tracked
+	err = os.WriteFile(dir+"/.dzhigitignore", []byte("build/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Add(gitDir, dir, []string{dir + "/tracked.log"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/.dzhigitignore", []byte(files[dir+"/.dzhigitignore"]), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := GitStatus(gitDir, dir, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "A  tracked.log\n?? .dzhigitignore\n?? src/.dzhigitignore\n?? src/keep.log\n"
+	if status.Porcelain() != expected {
+		t.Fatalf("Ignored files should not be untracked, expected\n%s\ngot\n%s", expected, status.Porcelain())
+	}
+	paths := []string{
+		dir + "/src/debug.log",
+		dir + "/src/keep.log",
+		dir + "/build/out",
+		dir + "/src/main.go",
+		dir + "/tracked.log",
+		dir + "/README",
+	}
+	matches, err := CheckIgnore(gitDir, dir, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 4 {
+		t.Fatalf("4 paths should match patterns, got %d", len(matches))
+	}
+	workTree := repository.WorkTreePath(gitDir)
+	verbose := []string{
+		".dzhigitignore:1:*.log\t" + paths[0],
+		"src/.dzhigitignore:1:!keep.log\t" + paths[1],
+		".dzhigitignore:2:build/\t" + paths[2],
+		".dzhigit/info/exclude:1:*.go\t" + paths[3],
+	}
+	for i, match := range matches {
+		if match.Verbose(workTree) != verbose[i] {
+			t.Fatalf("Wrong explanation, expected '%s', got '%s'", verbose[i], match.Verbose(workTree))
+		}
+	}
+	if matches[1].Ignored() {
+		t.Fatal("A negated pattern should re-include a file")
+	}
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//Print every commit of the current branch followed by its patch
+//The root commit is shown as an addition of all its files
+//With decorate commits are marked with branches and tags that point to them
+func LogPatch(
+	writer io.Writer,
+	gitRepoPath string,
+	context int,
+	decorate bool,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) error {
+	commitHash, err := headCommit(gitRepoPath, reader)
+	if err != nil {
+		return err
+	}
+	decorations, err := refDecorations(gitRepoPath, decorate, reader, store)
+	if err != nil {
+		return err
+	}
+	for len(commitHash) != 0 {
+		commit, err := readCommit(commitHash, store)
+		if err != nil {
+			return err
+		}
+		var parentTree repository.Hash
+		if commit.HasParent() {
+			parent, err := readCommit(commit.firstParent(), store)
+			if err != nil {
+				return err
+			}
+			parentTree = parent.treeHash
+		}
+		err = writeCommitHeader(writer, commitHash, decorations[commitHash], commit)
+		if err != nil {
+			return err
+		}
+		changes, err := diffTrees(
+			parentTree,
+			commit.treeHash,
+			"",
+			store,
+		)
+		if err != nil {
+			return err
+		}
+		err = writeCommitPatch(writer, changes, context, store)
+		if err != nil {
+			return err
+		}
+		commitHash = commit.firstParent()
+	}
+	return nil
+}
+
+//Refs pointing to commits in git log format
+//Example: " (HEAD -> master, tag: v1.0, feature)"
+//Without all only HEAD is shown, annotated tags are peeled to commits
+func refDecorations(
+	gitRepoPath string,
+	all bool,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (map[repository.Hash]string, error) {
+	refs := make(map[repository.Hash][]string)
+	branch, detached, err := readHead(gitRepoPath, reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(detached) != 0 {
+		refs[detached] = append(refs[detached], "HEAD")
+	} else if repository.Exists(repository.HeadsPath(gitRepoPath) + branch) {
+		tip, err := branchTip(gitRepoPath, branch, reader)
+		if err != nil {
+			return nil, err
+		}
+		refs[tip] = append(refs[tip], "HEAD -> "+branch)
+	}
+	if all {
+		tags, err := ListTags(gitRepoPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			target, err := tagTarget(gitRepoPath, tag, reader)
+			if err != nil {
+				return nil, err
+			}
+			peeled, _, err := peelTag(target, store)
+			if err != nil {
+				return nil, err
+			}
+			refs[peeled] = append(refs[peeled], "tag: "+tag)
+		}
+		branches, err := ListBranches(gitRepoPath, reader, store)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range branches {
+			//the current branch is already shown next to HEAD
+			if !info.Current {
+				refs[info.Commit] = append(refs[info.Commit], info.Name)
+			}
+		}
+	}
+	decorations := make(map[repository.Hash]string)
+	for hash, names := range refs {
+		decorations[hash] = fmt.Sprintf(" (%s)", strings.Join(names, ", "))
+	}
+	return decorations, nil
+}
+
+func writeCommitHeader(
+	writer io.Writer,
+	commitHash repository.Hash,
+	decoration string,
+	commit *Commit,
+) error {
+	message := strings.TrimRight(commit.message, "\n")
+	_, err := fmt.Fprintf(
+		writer,
+		"commit %s%s\nAuthor: %s\nDate:   %s\n\n    %s\n\n",
+		commitHash,
+		decoration,
+		commit.user.String(),
+		commit.time.String(),
+		strings.ReplaceAll(message, "\n", "\n    "),
+	)
+	return err
+}
+
+//print the list of changed files and a unified diff for each of them
+func writeCommitPatch(
+	writer io.Writer,
+	changes []treeChange,
+	context int,
+	store repository.ObjectStore,
+) error {
+	for _, change := range changes {
+		_, err := fmt.Fprintf(writer, " %s %s\n", change.status(), change.path)
+		if err != nil {
+			return err
+		}
+	}
+	if len(changes) != 0 {
+		_, err := fmt.Fprintln(writer)
+		if err != nil {
+			return err
+		}
+	}
+	for _, change := range changes {
+		var oldContent, newContent string
+		var err error
+		if change.old != nil {
+			oldContent, err = loadBlob(change.old.hash, store)
+			if err != nil {
+				return err
+			}
+		}
+		if change.new != nil {
+			newContent, err = loadBlob(change.new.hash, store)
+			if err != nil {
+				return err
+			}
+		}
+		err = writeFilePatch(
+			writer,
+			change.path,
+			change.old,
+			change.new,
+			oldContent,
+			newContent,
+			context,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(writer)
+	return err
+}
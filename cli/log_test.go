@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestLogPatch(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	err = os.MkdirAll(dir+"/src/inner", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/src/inner/file", []byte("old\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/removed", []byte("removed\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, gitDir, "First", dir+"/src", dir+"/removed")
+	err = os.WriteFile(dir+"/src/inner/file", []byte("new\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(dir + "/removed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := index.Read(repository.IndexPath(gitDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Remove("removed")
+	err = idx.Write(repository.IndexPath(gitDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, gitDir, "Second", dir+"/src")
+	var buffer bytes.Buffer
+	err = LogPatch(
+		&buffer,
+		gitDir,
+		3,
+		false,
+		repository.Reader,
+		store,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := buffer.String()
+	second := strings.Index(log, "    Second")
+	first := strings.Index(log, "    First")
+	if second == -1 || first == -1 || second > first {
+		t.Fatalf("Commits should be printed from newest to oldest\n%s", log)
+	}
+	expected := []string{
+		" D removed\n M src/inner/file\n",
+		"-old\n+new\n",
+		" A removed\n A src/inner/file\n",
+		"@@ -0,0 +1 @@\n+removed\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(log, part) {
+			t.Fatalf("Log should contain '%s'\n%s", part, log)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/strogiyotec/dzhigit/diff"
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//outcome of a merge
+type MergeResult struct {
+	UpToDate    bool            //the branch is already merged
+	FastForward bool            //the current branch was moved forward
+	Commit      repository.Hash //the new tip of the current branch
+	Conflicts   []string        //paths with conflicts, the merge is not committed
+}
+
+//Merge given branch into the current one
+//If the current branch is an ancestor of given one it's moved forward,
+//otherwise trees are merged using their lowest common ancestor as a base
+//and a commit with two parents is created.
+//Conflicting files are written with conflict markers and the merge
+//has to be finished with a regular commit
+func Merge(
+	gitRepoPath string,
+	workTree string,
+	branchName string,
+	user *User,
+	time *Time,
+	formatter repository.GitFileFormatter,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (*MergeResult, error) {
+	if repository.Exists(repository.MergeHeadPath(gitRepoPath)) {
+		return nil, errors.New(
+			"You have not concluded your merge (MERGE_HEAD exists), commit your changes first",
+		)
+	}
+	branch, err := currentBranch(gitRepoPath, reader)
+	if err != nil {
+		return nil, err
+	}
+	//label of our side in conflict markers
+	if len(branch) == 0 {
+		branch = "HEAD"
+	}
+	ours, err := headCommit(gitRepoPath, reader)
+	if err != nil {
+		return nil, err
+	}
+	err = repository.ValidateRefName(branchName)
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := branchTip(gitRepoPath, branchName, reader)
+	if err != nil {
+		return nil, err
+	}
+	status, err := GitStatus(gitRepoPath, workTree, formatter, reader, store)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Clean() {
+		return nil, errors.New(
+			"Your local changes would be overwritten by merge, commit them first",
+		)
+	}
+	base, err := mergeBase(ours, theirs, store)
+	if err != nil {
+		return nil, err
+	}
+	if base == theirs {
+		return &MergeResult{UpToDate: true, Commit: ours}, nil
+	}
+	oursCommit, err := readCommit(ours, store)
+	if err != nil {
+		return nil, err
+	}
+	theirsCommit, err := readCommit(theirs, store)
+	if err != nil {
+		return nil, err
+	}
+	if base == ours {
+		changes, err := diffTrees(
+			oursCommit.treeHash,
+			theirsCommit.treeHash,
+			"",
+			store,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = applyTreeChanges(workTree, changes, store)
+		if err != nil {
+			return nil, err
+		}
+		blobs, err := flattenTree(theirsCommit.treeHash, store)
+		if err != nil {
+			return nil, err
+		}
+		err = writeIndexFromTree(gitRepoPath, workTree, blobs)
+		if err != nil {
+			return nil, err
+		}
+		err = moveHead(
+			gitRepoPath,
+			ours,
+			theirs,
+			fmt.Sprintf("merge %s: Fast-forward", branchName),
+			reader,
+			store,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &MergeResult{FastForward: true, Commit: theirs}, nil
+	}
+	var baseTree repository.Hash
+	if len(base) != 0 {
+		baseCommit, err := readCommit(base, store)
+		if err != nil {
+			return nil, err
+		}
+		baseTree = baseCommit.treeHash
+	}
+	trees := make([]map[string]treeEntry, 3)
+	for i, treeHash := range []repository.Hash{baseTree, oursCommit.treeHash, theirsCommit.treeHash} {
+		trees[i], err = flattenTree(treeHash, store)
+		if err != nil {
+			return nil, err
+		}
+	}
+	merged, conflicts, err := mergeTrees(
+		trees[0],
+		trees[1],
+		trees[2],
+		branch,
+		branchName,
+		store,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = applyTreeChanges(
+		workTree,
+		diffFlatTrees(trees[1], merged),
+		store,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = writeIndexFromTree(gitRepoPath, workTree, merged)
+	if err != nil {
+		return nil, err
+	}
+	err = writeConflictStages(gitRepoPath, conflicts, trees)
+	if err != nil {
+		return nil, err
+	}
+	//the next commit gets MERGE_HEAD as a second parent
+	err = os.WriteFile(
+		repository.MergeHeadPath(gitRepoPath),
+		[]byte(theirs),
+		0644,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) != 0 {
+		var paths []string
+		for path, conflict := range conflicts {
+			err = writeWorkTreeFile(workTree, path, []byte(conflict.content), conflict.mode)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		return &MergeResult{Conflicts: paths}, nil
+	}
+	commitHash, err := commitIndex(
+		gitRepoPath,
+		ours,
+		fmt.Sprintf("Merge branch '%s'", branchName),
+		true,
+		false,
+		user,
+		time,
+		reader,
+		store,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &MergeResult{Commit: commitHash}, nil
+}
+
+//replace index entries of conflicting paths with their versions from base, ours and theirs trees
+//in stages 1-3, so the merge can't be committed until every path is added again
+func writeConflictStages(
+	gitRepoPath string,
+	conflicts map[string]mergeConflict,
+	trees []map[string]treeEntry,
+) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	indexPath := repository.IndexPath(gitRepoPath)
+	idx, err := index.Read(indexPath)
+	if err != nil {
+		return err
+	}
+	for path := range conflicts {
+		idx.Remove(path)
+		for i, stage := range []index.Stage{index.Base, index.Ours, index.Theirs} {
+			if entry, ok := trees[i][path]; ok {
+				idx.Add(index.NewEntry(path, entry.mode, entry.hash, index.Stat{}).WithStage(stage))
+			}
+		}
+	}
+	return idx.Write(indexPath)
+}
+
+//content of a file that couldn't be merged automatically
+type mergeConflict struct {
+	content string
+	mode    repository.Mode
+}
+
+//Three-way merge of flattened trees
+//Returns merged blobs and conflicts by path,
+//a conflicting path keeps our version in merged blobs
+func mergeTrees(
+	base map[string]treeEntry,
+	ours map[string]treeEntry,
+	theirs map[string]treeEntry,
+	oursLabel string,
+	theirsLabel string,
+	store repository.ObjectStore,
+) (map[string]treeEntry, map[string]mergeConflict, error) {
+	paths := make(map[string]bool)
+	for _, tree := range []map[string]treeEntry{base, ours, theirs} {
+		for path := range tree {
+			paths[path] = true
+		}
+	}
+	merged := make(map[string]treeEntry)
+	conflicts := make(map[string]mergeConflict)
+	for path := range paths {
+		baseEntry, inBase := base[path]
+		oursEntry, inOurs := ours[path]
+		theirsEntry, inTheirs := theirs[path]
+		switch {
+		case sameBlob(oursEntry, inOurs, theirsEntry, inTheirs),
+			sameBlob(baseEntry, inBase, theirsEntry, inTheirs):
+			if inOurs {
+				merged[path] = oursEntry
+			}
+		case sameBlob(baseEntry, inBase, oursEntry, inOurs):
+			if inTheirs {
+				merged[path] = theirsEntry
+			}
+		case !inOurs || !inTheirs:
+			//modified on one side and deleted on another
+			kept := oursEntry
+			if !inOurs {
+				kept = theirsEntry
+			}
+			content, err := loadBlob(kept.hash, store)
+			if err != nil {
+				return nil, nil, err
+			}
+			merged[path] = kept
+			conflicts[path] = mergeConflict{content: content, mode: kept.mode}
+		default:
+			//changed on both sides
+			var baseContent string
+			var err error
+			if inBase {
+				baseContent, err = loadBlob(baseEntry.hash, store)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			oursContent, err := loadBlob(oursEntry.hash, store)
+			if err != nil {
+				return nil, nil, err
+			}
+			theirsContent, err := loadBlob(theirsEntry.hash, store)
+			if err != nil {
+				return nil, nil, err
+			}
+			content, conflict := diff.Merge(
+				baseContent,
+				oursContent,
+				theirsContent,
+				oursLabel,
+				theirsLabel,
+			)
+			mode := oursEntry.mode
+			if inBase && oursEntry.mode == baseEntry.mode {
+				mode = theirsEntry.mode
+			}
+			if conflict {
+				merged[path] = oursEntry
+				conflicts[path] = mergeConflict{content: content, mode: mode}
+				continue
+			}
+			blob, err := store.Put(repository.BLOB, []byte(content))
+			if err != nil {
+				return nil, nil, err
+			}
+			merged[path] = treeEntry{
+				mode:    mode,
+				objType: repository.BLOB,
+				path:    path,
+				hash:    blob,
+			}
+		}
+	}
+	return merged, conflicts, nil
+}
+
+//check if two optional blobs are the same, two missing blobs are the same too
+func sameBlob(first treeEntry, firstExists bool, second treeEntry, secondExists bool) bool {
+	if firstExists != secondExists {
+		return false
+	}
+	return !firstExists || (first.hash == second.hash && first.mode == second.mode)
+}
+
+//Find the lowest common ancestor of two commits
+//A common ancestor is the lowest if it's not an ancestor of another common ancestor
+//Returns an empty hash if commits have unrelated histories
+func mergeBase(
+	first repository.Hash,
+	second repository.Hash,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	firstAncestors, _, err := ancestors(first, store)
+	if err != nil {
+		return "", err
+	}
+	_, order, err := ancestors(second, store)
+	if err != nil {
+		return "", err
+	}
+	var common []repository.Hash
+	for _, hash := range order {
+		if firstAncestors[hash] {
+			common = append(common, hash)
+		}
+	}
+	//every ancestor of a common ancestor is not the lowest one
+	notLowest := make(map[repository.Hash]bool)
+	var queue []repository.Hash
+	for _, hash := range common {
+		commit, err := readCommit(hash, store)
+		if err != nil {
+			return "", err
+		}
+		queue = append(queue, commit.parentHashes...)
+	}
+	for len(queue) != 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if notLowest[hash] {
+			continue
+		}
+		notLowest[hash] = true
+		commit, err := readCommit(hash, store)
+		if err != nil {
+			return "", err
+		}
+		queue = append(queue, commit.parentHashes...)
+	}
+	for _, hash := range common {
+		if !notLowest[hash] {
+			return hash, nil
+		}
+	}
+	return "", nil
+}
+
+//all commits reachable from given one including itself
+//returns a set of commits and the order they were visited in (breadth first)
+func ancestors(
+	commitHash repository.Hash,
+	store repository.ObjectStore,
+) (map[repository.Hash]bool, []repository.Hash, error) {
+	visited := map[repository.Hash]bool{commitHash: true}
+	order := []repository.Hash{commitHash}
+	for i := 0; i < len(order); i++ {
+		commit, err := readCommit(order[i], store)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, parent := range commit.parentHashes {
+			if !visited[parent] {
+				visited[parent] = true
+				order = append(order, parent)
+			}
+		}
+	}
+	return visited, order, nil
+}
@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//move HEAD to another branch updating the working tree and the index
+func switchBranch(t *testing.T, gitDir string, branch string) {
+	err := Checkout(
+		gitDir,
+		repository.WorkTreePath(gitDir),
+		branch,
+		false,
+		repository.Reader,
+		repository.NewFileStore(repository.ObjPath(gitDir)),
+		&repository.DefaultGitFileFormatter{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mergeBranch(t *testing.T, gitDir string, branch string) *MergeResult {
+	result, err := Merge(
+		gitDir,
+		repository.WorkTreePath(gitDir),
+		branch,
+		&User{Name: "Almas", Email: "almas337519@gmail.com"},
+		CurrentTime(),
+		&repository.DefaultGitFileFormatter{},
+		repository.Reader,
+		repository.NewFileStore(repository.ObjPath(gitDir)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestMerge(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	err = os.WriteFile(dir+"/file", []byte("a\nb\nc\nd\ne\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := commitFiles(t, gitDir, "Base", dir+"/file")
+	err = writeBranch(gitDir, "feature", "", base, "branch: Created from HEAD", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//fast-forward of feature to master
+	err = os.WriteFile(dir+"/file", []byte("A\nb\nc\nd\ne\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours := commitFiles(t, gitDir, "Ours", dir+"/file")
+	switchBranch(t, gitDir, "feature")
+	result := mergeBranch(t, gitDir, repository.DefaultBranch)
+	if !result.FastForward || result.Commit != ours {
+		t.Fatalf("Merge should fast-forward to '%s', got %+v", ours, result)
+	}
+	//a change in another part of the file merges cleanly
+	switchBranch(t, gitDir, repository.DefaultBranch)
+	err = writeBranch(gitDir, "feature", ours, base, "branch: Created from HEAD", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switchBranch(t, gitDir, "feature")
+	err = os.WriteFile(dir+"/file", []byte("a\nb\nc\nd\nE\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs := commitFiles(t, gitDir, "Theirs", dir+"/file")
+	switchBranch(t, gitDir, repository.DefaultBranch)
+	result = mergeBranch(t, gitDir, "feature")
+	if len(result.Conflicts) != 0 || result.FastForward {
+		t.Fatalf("Three-way merge expected, got %+v", result)
+	}
+	mergeCommit, err := readCommit(result.Commit, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mergeCommit.parentHashes) != 2 ||
+		mergeCommit.parentHashes[0] != ours ||
+		mergeCommit.parentHashes[1] != theirs {
+		t.Fatalf("Merge commit should have parents '%s' and '%s', got %v", ours, theirs, mergeCommit.parentHashes)
+	}
+	content, err := os.ReadFile(dir + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "A\nb\nc\nd\nE\n" {
+		t.Fatalf("Wrong merged content '%s'", content)
+	}
+	if mergeResult := mergeBranch(t, gitDir, "feature"); !mergeResult.UpToDate {
+		t.Fatalf("Merged branch should be up to date, got %+v", mergeResult)
+	}
+	//an amended merge commit keeps both parents
+	amended, err := CommitIndex(
+		gitDir,
+		"Amended merge",
+		false,
+		true,
+		&User{Name: "Almas", Email: "almas337519@gmail.com"},
+		CurrentTime(),
+		repository.Reader,
+		store,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amendedCommit, err := readCommit(amended, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(amendedCommit.parentHashes) != 2 ||
+		amendedCommit.parentHashes[0] != ours ||
+		amendedCommit.parentHashes[1] != theirs {
+		t.Fatalf("Amended merge should have parents '%s' and '%s', got %v", ours, theirs, amendedCommit.parentHashes)
+	}
+}
+
+func TestMerge_conflict(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	err = os.WriteFile(dir+"/file", []byte("line\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := commitFiles(t, gitDir, "Base", dir+"/file")
+	err = writeBranch(gitDir, "feature", "", base, "branch: Created from HEAD", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/file", []byte("ours\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours := commitFiles(t, gitDir, "Ours", dir+"/file")
+	switchBranch(t, gitDir, "feature")
+	err = os.WriteFile(dir+"/file", []byte("theirs\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs := commitFiles(t, gitDir, "Theirs", dir+"/file")
+	switchBranch(t, gitDir, repository.DefaultBranch)
+	result := mergeBranch(t, gitDir, "feature")
+	if len(result.Conflicts) != 1 || result.Conflicts[0] != "file" {
+		t.Fatalf("Conflict in 'file' expected, got %+v", result)
+	}
+	content, err := os.ReadFile(dir + "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<<<<<<< master\nours\n=======\ntheirs\n>>>>>>> feature\n"
+	if string(content) != expected {
+		t.Fatalf("Wrong conflict markers, expected\n%s\ngot\n%s", expected, content)
+	}
+	//versions of the conflicting file are kept in stages until it's resolved
+	idx, err := index.Read(repository.IndexPath(gitDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Conflicts()) != 1 || idx.Conflicts()[0] != "file" || idx.Len() != 3 {
+		t.Fatalf("Expected base, ours and theirs stages of 'file', got %v", idx.Entries())
+	}
+	if _, ok := idx.Get("file"); ok {
+		t.Fatal("A conflicting path should not have a merged entry")
+	}
+	status, err := GitStatus(gitDir, dir, &repository.DefaultGitFileFormatter{}, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Porcelain() != "UU file\n" {
+		t.Fatalf("Expected an unmerged file, got\n%s", status.Porcelain())
+	}
+	_, err = CommitIndex(
+		gitDir,
+		"Merge",
+		false,
+		false,
+		&User{Name: "Almas", Email: "almas337519@gmail.com"},
+		CurrentTime(),
+		repository.Reader,
+		store,
+	)
+	if err == nil {
+		t.Fatal("A merge with unmerged files should not be committed")
+	}
+	//resolve and commit
+	err = os.WriteFile(dir+"/file", []byte("resolved\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved := commitFiles(t, gitDir, "Resolved", dir+"/file")
+	commit, err := readCommit(resolved, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commit.parentHashes) != 2 || commit.parentHashes[0] != ours || commit.parentHashes[1] != theirs {
+		t.Fatalf("Resolved merge should have two parents, got %v", commit.parentHashes)
+	}
+	if repository.Exists(repository.MergeHeadPath(gitDir)) {
+		t.Fatal("MERGE_HEAD should be removed after commit")
+	}
+	if !strings.HasPrefix(commit.message, "Resolved") {
+		t.Fatalf("Wrong message '%s'", commit.message)
+	}
+}
@@ -0,0 +1,279 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//offsets of zone names that older versions wrote instead of an offset
+var zoneOffsets = map[string]string{
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"IST":  "+0530",
+	"HKT":  "+0800",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"AWST": "+0800",
+	"ACST": "+0930",
+	"ACDT": "+1030",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+	"HST":  "-1000",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+}
+
+//rewrites objects written by older versions in the format of git
+//hashes of rewritten objects are remembered so each object is visited once
+type migration struct {
+	store     repository.ObjectStore
+	rewritten map[repository.Hash]repository.Hash
+}
+
+//Rewrite the history reachable from refs, HEAD and MERGE_HEAD in the format of git
+//Text trees are encoded as binary ones, the misspelled "comitter" header is fixed
+//and zone names like "UTC" in identities are replaced with offsets like "+0000",
+//so commits and tags pointing to them get new hashes as well.
+//Refs and logs are updated to the new hashes, old objects are kept.
+//Returns old hashes of objects that changed mapped to the new ones
+func MigrateObjects(
+	gitRepoPath string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (map[repository.Hash]repository.Hash, error) {
+	m := &migration{
+		store:     store,
+		rewritten: make(map[repository.Hash]repository.Hash),
+	}
+	refFiles := []string{repository.HeadPath(gitRepoPath), repository.MergeHeadPath(gitRepoPath)}
+	err := filepath.Walk(
+		gitRepoPath+repository.Refs,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			//lock of a ref that is being written by another process
+			if repository.IsLockFile(path) {
+				return nil
+			}
+			refFiles = append(refFiles, path)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, refFile := range refFiles {
+		err = m.migrateRef(refFile, reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+	logsPath := gitRepoPath + repository.Logs
+	if repository.Exists(logsPath) {
+		err = filepath.Walk(
+			logsPath,
+			func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() || repository.IsLockFile(path) {
+					return err
+				}
+				return m.migrateLog(path, reader)
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	changed := make(map[repository.Hash]repository.Hash)
+	for old, new := range m.rewritten {
+		if old != new {
+			changed[old] = new
+		}
+	}
+	return changed, nil
+}
+
+//point a ref file to the migrated object, a symbolic HEAD is left as is
+func (m *migration) migrateRef(refFile string, reader repository.FileReader) error {
+	if !repository.Exists(refFile) {
+		return nil
+	}
+	content, err := reader(refFile)
+	if err != nil {
+		return err
+	}
+	hash, err := repository.NewHash(strings.TrimSpace(string(content)))
+	if err != nil {
+		//HEAD that refers to a branch
+		return nil
+	}
+	migrated, err := m.object(hash)
+	if err != nil {
+		return err
+	}
+	if migrated == hash {
+		return nil
+	}
+	return repository.CompareAndSwap(refFile, content, []byte(migrated), 0755)
+}
+
+//replace old and new hashes of log entries,
+//commits that are no longer reachable keep their hashes
+func (m *migration) migrateLog(logFile string, reader repository.FileReader) error {
+	content, err := reader(logFile)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for j := 0; j < 2; j++ {
+			if migrated, ok := m.rewritten[repository.Hash(fields[j])]; ok {
+				fields[j] = string(migrated)
+			}
+		}
+		lines[i] = strings.Join(fields, " ")
+	}
+	//the log is replaced under its lock and only if nothing was appended after it was read
+	return repository.CompareAndSwap(logFile, content, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+//hash of an object after migration, objects it refers to are migrated first
+func (m *migration) object(hash repository.Hash) (repository.Hash, error) {
+	if migrated, ok := m.rewritten[hash]; ok {
+		return migrated, nil
+	}
+	deser, err := m.store.Get(hash)
+	if err != nil {
+		return "", err
+	}
+	var content []byte
+	switch deser.ObjType {
+	case repository.TREE:
+		content, err = m.tree(deser.Content)
+	case repository.COMMIT, repository.TAG:
+		content, err = m.header(deser.Content)
+	default:
+		m.rewritten[hash] = hash
+		return hash, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	//an object that didn't change already exists and is not written again
+	migrated, err := m.store.Put(deser.ObjType, content)
+	if err != nil {
+		return "", err
+	}
+	m.rewritten[hash] = migrated
+	return migrated, nil
+}
+
+//encode a tree in the binary format with migrated subtrees
+func (m *migration) tree(content string) ([]byte, error) {
+	entries, err := repository.DecodeTree(content)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		if entry.Mode != repository.DIR {
+			continue
+		}
+		entries[i].Hash, err = m.object(entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return repository.EncodeTree(entries)
+}
+
+//rewrite headers of a commit or a tag that refer to other objects,
+//the message and the rest of the headers are kept byte by byte
+func (m *migration) header(content string) ([]byte, error) {
+	parts := strings.SplitN(content, "\n\n", 2)
+	lines := strings.Split(parts[0], "\n")
+	for i, line := range lines {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "tree", "parent", "object":
+			hash, err := repository.NewHash(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			migrated, err := m.object(hash)
+			if err != nil {
+				return nil, err
+			}
+			lines[i] = fields[0] + " " + string(migrated)
+		case "author", "committer", "tagger":
+			lines[i] = fields[0] + " " + migrateIdentity(fields[1])
+		case "comitter":
+			lines[i] = "committer " + migrateIdentity(fields[1])
+		}
+	}
+	parts[0] = strings.Join(lines, "\n")
+	return []byte(strings.Join(parts, "\n\n")), nil
+}
+
+//replace a zone name at the end of "Name <email> unixSeconds zone" with an offset
+func migrateIdentity(identity string) string {
+	zoneStart := strings.LastIndex(identity, " ")
+	if zoneStart == -1 {
+		return identity
+	}
+	secondsStart := strings.LastIndex(identity[:zoneStart], " ")
+	seconds, err := strconv.ParseInt(identity[secondsStart+1:zoneStart], 10, 64)
+	if err != nil {
+		return identity
+	}
+	return identity[:zoneStart+1] + zoneOffset(identity[zoneStart+1:], seconds)
+}
+
+//offset like "-0700" of a zone at given time
+//Example: "PDT" -> "-0700", "+06" -> "+0600", "Asia/Almaty" -> "+0600"
+func zoneOffset(zone string, unixSeconds int64) string {
+	//zones without a name are written like "+06" or "+0530"
+	if (len(zone) == 3 || len(zone) == 5) && strings.ContainsAny(zone[:1], "+-") &&
+		len(strings.Trim(zone[1:], "0123456789")) == 0 {
+		return (zone + "00")[:5]
+	}
+	if offset, ok := zoneOffsets[zone]; ok {
+		return offset
+	}
+	location, err := time.LoadLocation(zone)
+	if err == nil {
+		return time.Unix(unixSeconds, 0).In(location).Format("-0700")
+	}
+	//the time itself is in unix seconds, only the offset it's shown with is unknown
+	return "+0000"
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestMigrateObjects(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	save := func(content string, objType repository.GitObjectType) repository.Hash {
+		hash, err := store.Put(objType, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	hello := save("hello\n", repository.BLOB)
+	run := save("#!/bin/sh\necho hi\n", repository.BLOB)
+	x := save("x", repository.BLOB)
+	//objects in the format of older versions
+	sub := save("100644 blob "+string(x)+"\tinner.txt\n", repository.TREE)
+	tree := save(
+		"100644 blob "+string(hello)+"\thello.txt\n"+
+			"100755 blob "+string(run)+"\trun.sh\n"+
+			"040000 tree "+string(sub)+"\tlib\n"+
+			"100644 blob "+string(x)+"\tlib.go\n"+
+			"100644 blob "+string(x)+"\tlib-a\n",
+		repository.TREE,
+	)
+	identity := "Almas Abdrazak <almas337519@gmail.com> 1630023095 -0700"
+	commit := save(
+		"tree "+string(tree)+"\nauthor "+identity+"\ncomitter "+identity+"\n\nInitial commit\n",
+		repository.COMMIT,
+	)
+	tag := save(
+		"object "+string(commit)+"\ntype commit\ntag v1.0\ntagger "+identity+"\n\nRelease\n",
+		repository.TAG,
+	)
+	//older versions wrote zone names instead of offsets
+	empty := save("", repository.TREE)
+	zoned := save(
+		"tree "+string(empty)+
+			"\nauthor Almas Abdrazak <almas337519@gmail.com> 1630023095 UTC"+
+			"\ncomitter Almas Abdrazak <almas337519@gmail.com> 1630023095 PDT\n\nZoned commit\n",
+		repository.COMMIT,
+	)
+	//text trees are still readable before the migration
+	blobs, err := flattenTree(tree, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blobs["lib/inner.txt"].hash != x {
+		t.Fatalf("Text tree should be read, got %v", blobs)
+	}
+	err = os.WriteFile(repository.HeadsPath(gitDir)+"master", []byte(commit), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(repository.HeadsPath(gitDir)+"zoned", []byte(zoned), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(repository.TagsPath(gitDir)+"v1.0", []byte(tag), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = appendReflog(gitDir, branchRef("master"), "", commit, "commit (initial): Initial commit", repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := MigrateObjects(gitDir, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 5 || changed[commit] != gitCommit || changed[tag] != gitTag {
+		t.Fatalf("Two trees, two commits and the tag should be rewritten, got %v", changed)
+	}
+	//hash of the commit with "+0000" and "-0700" offsets as computed by git
+	if changed[zoned] != "17f08bde8d8b010276d72088ceff3cdc19ce1eac" {
+		t.Fatalf("Zone names should be replaced with offsets, got %s", changed[zoned])
+	}
+	tip, err := branchTip(gitDir, "master", repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip != gitCommit {
+		t.Fatalf("master should point to git commit '%s', got '%s'", gitCommit, tip)
+	}
+	tagged, err := tagTarget(gitDir, "v1.0", repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tagged != gitTag {
+		t.Fatalf("v1.0 should point to git tag '%s', got '%s'", gitTag, tagged)
+	}
+	entries, err := ReadReflog(gitDir, branchRef("master"), repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].New != gitCommit {
+		t.Fatalf("Log of master should refer to the migrated commit, got %v", entries)
+	}
+	if repository.Exists(repository.LogPath(gitDir, branchRef("master")) + repository.LockExt) {
+		t.Fatal("Lock of the log was kept")
+	}
+	//a second run has nothing to migrate
+	changed, err = MigrateObjects(gitDir, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Fatalf("Migrated repository should not change, got %v", changed)
+	}
+}
+
+func Test_zoneOffset(t *testing.T) {
+	for zone, expected := range map[string]string{
+		"+0300": "+0300",
+		"+06":   "+0600",
+		"-03":   "-0300",
+		"UTC":   "+0000",
+		"PDT":   "-0700",
+		"CEST":  "+0200",
+		"XYZ":   "+0000",
+	} {
+		if offset := zoneOffset(zone, 1630023095); offset != expected {
+			t.Errorf("Expected offset %s of %s, got %s", expected, zone, offset)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/pack"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//Write objects into a new pack of the repository
+//All loose objects are packed if no hashes are given, loose files are kept
+//Returns the path of the pack
+func PackObjects(
+	store *repository.FileStore,
+	hashes []repository.Hash,
+	options pack.Options,
+) (string, error) {
+	if len(hashes) == 0 {
+		loose, err := repository.Hashes(store.Loose)
+		if err != nil {
+			return "", err
+		}
+		hashes = loose
+	}
+	if len(hashes) == 0 {
+		return "", errors.New("There are no objects to pack")
+	}
+	objects := make([]pack.Object, 0, len(hashes))
+	for _, hash := range hashes {
+		object, err := packObject(store, hash)
+		if err != nil {
+			return "", err
+		}
+		objects = append(objects, object)
+	}
+	return pack.Create(store.Packs.Dir(), objects, options)
+}
+
+//read an object in a form that can be packed, its content must match the hash
+func packObject(store repository.ObjectStore, hash repository.Hash) (pack.Object, error) {
+	deser, err := store.Get(hash)
+	if err != nil {
+		return pack.Object{}, err
+	}
+	objType, err := pack.TypeOf(string(deser.ObjType))
+	if err != nil {
+		return pack.Object{}, err
+	}
+	object := pack.NewObject(objType, []byte(deser.Content))
+	if object.Hash != string(hash) {
+		return pack.Object{}, errors.New(
+			fmt.Sprintf("Object %s is corrupted, its content has hash %s", hash, object.Hash),
+		)
+	}
+	return object, nil
+}
+
+//Build an index for a pack, returns the checksum of the pack
+func IndexPack(packPath string) (string, error) {
+	idx, err := pack.IndexPack(packPath)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(idx.PackChecksum), nil
+}
+
+//Verify a pack given by a path of the pack or its index
+//verbose - print every object and a histogram of delta chains like "git verify-pack -v"
+func VerifyPack(out io.Writer, path string, verbose bool) error {
+	packPath := strings.TrimSuffix(strings.TrimSuffix(path, pack.IndexExt), pack.PackExt) + pack.PackExt
+	objects, err := pack.Verify(packPath)
+	if err != nil {
+		return err
+	}
+	if !verbose {
+		return nil
+	}
+	chains := make(map[int]int)
+	longest := 0
+	for _, object := range objects {
+		//"<hash> <type> <size> <size in pack> <offset> [<depth> <base>]"
+		line := fmt.Sprintf(
+			"%s %-6s %d %d %d",
+			object.Hash,
+			object.Type,
+			object.Size,
+			object.PackedSize,
+			object.Offset,
+		)
+		if object.Depth > 0 {
+			line += fmt.Sprintf(" %d %s", object.Depth, object.Base)
+		}
+		fmt.Fprintln(out, line)
+		chains[object.Depth]++
+		if object.Depth > longest {
+			longest = object.Depth
+		}
+	}
+	fmt.Fprintf(out, "non delta: %d %s\n", chains[0], objectsWord(chains[0]))
+	for depth := 1; depth <= longest; depth++ {
+		if chains[depth] > 0 {
+			fmt.Fprintf(out, "chain length = %d: %d %s\n", depth, chains[depth], objectsWord(chains[depth]))
+		}
+	}
+	fmt.Fprintf(out, "%s: ok\n", packPath)
+	return nil
+}
+
+func objectsWord(count int) string {
+	if count == 1 {
+		return "object"
+	}
+	return "objects"
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/pack"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestPackObjects(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	content := ""
+	for i := 0; i < 5; i++ {
+		content += fmt.Sprintf("line %d of a file that grows with every commit\n", i)
+		err = os.WriteFile(dir+"/file", []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commitFiles(t, gitDir, fmt.Sprintf("Commit %d", i), dir+"/file")
+	}
+	loose, err := repository.Hashes(store.Loose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packPath, err := PackObjects(store, nil, pack.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = VerifyPack(&out, pack.IndexPath(packPath), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "chain length = 1") ||
+		!strings.HasSuffix(out.String(), packPath+": ok\n") {
+		t.Fatalf("Blobs should be stored as deltas, got\n%s", out.String())
+	}
+	//history is read from the pack once loose objects are gone
+	for _, hash := range loose {
+		store.Loose.Remove(hash)
+	}
+	head, err := ResolveRevision(gitDir, "HEAD~4", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deser, err := GitCat(head, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(deser.Content, "Commit 0\n") {
+		t.Fatalf("Wrong first commit '%s'", deser.Content)
+	}
+	os.Remove(pack.IndexPath(packPath))
+	_, err = IndexPack(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = PackObjects(store, nil, pack.DefaultOptions)
+	if err == nil {
+		t.Fatal("There should be no loose objects to pack")
+	}
+}
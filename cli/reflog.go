@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//hash written to a log when a ref didn't point to a commit before
+var zeroHash = repository.Hash(strings.Repeat("0", 40))
+
+//prefix of a log message written by checkout
+const checkoutMessage = "checkout: moving from "
+
+//name of the log of HEAD movements
+const headRef = "HEAD"
+
+//single movement of a ref
+type ReflogEntry struct {
+	Old     repository.Hash //empty if the ref was created
+	New     repository.Hash
+	User    *User
+	Time    *Time
+	Message string //the reason of the movement
+}
+
+//full name of a branch ref
+//Example: "master" -> "refs/heads/master"
+func branchRef(branch string) string {
+	return strings.TrimPrefix(repository.PathToBranch(branch), "/")
+}
+
+//Record a movement of a ref in logs/<ref>
+//Line format: "<old> <new> <name> <<email>> <unix seconds> <zone>\t<message>"
+func appendReflog(
+	gitRepoPath string,
+	ref string,
+	old repository.Hash,
+	new repository.Hash,
+	message string,
+	reader repository.FileReader,
+) error {
+	content, err := reader(repository.ConfigPath(gitRepoPath))
+	if err != nil {
+		return err
+	}
+	user, err := NewUser(content)
+	if err != nil {
+		return err
+	}
+	if len(old) == 0 {
+		old = zeroHash
+	}
+	time := CurrentTime()
+	logPath := repository.LogPath(gitRepoPath, ref)
+	err = os.MkdirAll(filepath.Dir(logPath), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	//a message is always a single line
+	message = strings.SplitN(message, "\n", 2)[0]
+	_, err = fmt.Fprintf(
+		f,
+		"%s %s %s <%s> %d %s\t%s\n",
+		old,
+		new,
+		user.Name,
+		user.Email,
+		time.unixSeconds,
+		time.zone,
+		message,
+	)
+	return err
+}
+
+//Read the log of a ref, the newest entry goes first
+//A ref without a log has no entries
+func ReadReflog(
+	gitRepoPath string,
+	ref string,
+	reader repository.FileReader,
+) ([]ReflogEntry, error) {
+	logPath := repository.LogPath(gitRepoPath, ref)
+	if !repository.Exists(logPath) {
+		return nil, nil
+	}
+	content, err := reader(logPath)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ReflogEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		entry, err := parseReflogEntry(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append([]ReflogEntry{*entry}, entries...)
+	}
+	return entries, nil
+}
+
+//Full name of a ref whose log should be read
+//"HEAD" and an empty name mean HEAD, any other name is a branch
+func ReflogRef(name string) string {
+	if len(name) == 0 || name == headRef {
+		return headRef
+	}
+	if strings.HasPrefix(name, "refs/") {
+		return name
+	}
+	return branchRef(name)
+}
+
+func parseReflogEntry(line string) (*ReflogEntry, error) {
+	parts := strings.SplitN(line, "\t", 2)
+	fields := strings.SplitN(parts[0], " ", 3)
+	if len(fields) != 3 {
+		return nil, errors.New(
+			fmt.Sprintf("Invalid reflog entry '%s'", line),
+		)
+	}
+	old, err := repository.NewHash(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	if old == zeroHash {
+		old = ""
+	}
+	new, err := repository.NewHash(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	user, time, err := parseIdentity(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	entry := &ReflogEntry{Old: old, New: new, User: user, Time: time}
+	if len(parts) == 2 {
+		entry.Message = parts[1]
+	}
+	return entry, nil
+}
+
+//Name of a branch that was checked out n checkouts ago
+func previousBranch(
+	gitRepoPath string,
+	n int,
+	reader repository.FileReader,
+) (string, error) {
+	entries, err := ReadReflog(gitRepoPath, headRef, reader)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Message, checkoutMessage) {
+			continue
+		}
+		n--
+		if n == 0 {
+			moving := strings.TrimPrefix(entry.Message, checkoutMessage)
+			return strings.SplitN(moving, " to ", 2)[0], nil
+		}
+	}
+	return "", errors.New("There is no previous branch to check out")
+}
+
+//Value of a ref n movements ago, the zeroth entry is the current value
+func reflogValue(
+	gitRepoPath string,
+	ref string,
+	n int,
+	reader repository.FileReader,
+) (repository.Hash, error) {
+	entries, err := ReadReflog(gitRepoPath, ref, reader)
+	if err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		return "", errors.New(
+			fmt.Sprintf("log for '%s' only has %d entries", ref, len(entries)),
+		)
+	}
+	return entries[n].New, nil
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestReflog(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	err = os.WriteFile(dir+"/file", []byte("first"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFiles(t, gitDir, "First", dir+"/file")
+	err = os.WriteFile(dir+"/file", []byte("second"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := commitFiles(t, gitDir, "Second\n\nWith body", dir+"/file")
+	err = CreateBranch(gitDir, "feature", "HEAD~1", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switchBranch(t, gitDir, "feature")
+	//a mistaken reset of master
+	err = UpdateBranch(gitDir, "master", first, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadReflog(gitDir, ReflogRef("master"), repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ReflogEntry{
+		{Old: second, New: first, Message: "update-ref"},
+		{Old: first, New: second, Message: "commit: Second"},
+		{Old: "", New: first, Message: "commit (initial): First"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), entries)
+	}
+	for i := range expected {
+		if entries[i].Old != expected[i].Old ||
+			entries[i].New != expected[i].New ||
+			entries[i].Message != expected[i].Message {
+			t.Errorf("Expected entry %+v, got %+v", expected[i], entries[i])
+		}
+		if entries[i].User == nil || len(entries[i].User.Email) == 0 {
+			t.Errorf("Entry %d has no identity", i)
+		}
+	}
+	head, err := ReadReflog(gitDir, ReflogRef(""), repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(head) != 3 || head[0].Message != "checkout: moving from master to feature" {
+		t.Fatalf("Wrong log of HEAD %+v", head)
+	}
+	resolve := func(revision string) repository.Hash {
+		hash, err := ResolveRevision(gitDir, revision, repository.Reader, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	//the commit lost by the reset is still reachable
+	if resolve("master@{1}") != second || resolve("master@{0}") != first || resolve("master@{2}") != first {
+		t.Fatal("Wrong values of master from its log")
+	}
+	if resolve("HEAD@{1}") != second || resolve("@{0}") != first || resolve("master@{1}~1") != first {
+		t.Fatal("Wrong values of HEAD from its log")
+	}
+	_, err = ResolveRevision(gitDir, "master@{3}", repository.Reader, store)
+	if err == nil {
+		t.Fatal("Revision beyond the log was resolved")
+	}
+	err = RenameBranch(gitDir, "feature", "topic/feature", repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := ReadReflog(gitDir, ReflogRef("topic/feature"), repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renamed) != 2 || renamed[0].Message != "Branch: renamed refs/heads/feature to refs/heads/topic/feature" ||
+		renamed[1].Message != "branch: Created from HEAD~1" {
+		t.Fatalf("Log wasn't moved with the branch %+v", renamed)
+	}
+	switchBranch(t, gitDir, "master")
+	_, err = DeleteBranch(gitDir, "topic/feature", true, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repository.Exists(repository.LogPath(gitDir, "refs/heads/topic")) {
+		t.Fatal("Log of a deleted branch was kept")
+	}
+}
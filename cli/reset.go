@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//what reset rewrites besides the current branch
+type ResetMode string
+
+const (
+	SoftReset  ResetMode = "soft"  //only the current branch is moved
+	MixedReset ResetMode = "mixed" //the index is rebuilt from the target tree
+	HardReset  ResetMode = "hard"  //the index and the working tree match the target tree
+)
+
+//Move the current branch (or a detached HEAD) to given revision
+//An empty revision means HEAD, so "reset --hard" discards all local changes
+func Reset(
+	gitRepoPath string,
+	workTree string,
+	revision string,
+	mode ResetMode,
+	formatter repository.GitFileFormatter,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	if len(revision) == 0 {
+		revision = "HEAD"
+	}
+	target, err := ResolveCommit(gitRepoPath, revision, reader, store)
+	if err != nil {
+		return "", err
+	}
+	//the branch is only moved if nobody moved it during the reset
+	head, err := headTip(gitRepoPath, reader)
+	if err != nil {
+		return "", err
+	}
+	mergeHeadPath := repository.MergeHeadPath(gitRepoPath)
+	if mode == SoftReset && repository.Exists(mergeHeadPath) {
+		return "", errors.New("Cannot do a soft reset in the middle of a merge")
+	}
+	commit, err := readCommit(target, store)
+	if err != nil {
+		return "", err
+	}
+	blobs, err := flattenTree(commit.treeHash, store)
+	if err != nil {
+		return "", err
+	}
+	switch mode {
+	case HardReset:
+		status, err := GitStatus(gitRepoPath, workTree, formatter, reader, store)
+		if err != nil {
+			return "", err
+		}
+		oldTree, err := headTree(gitRepoPath, reader, store)
+		if err != nil {
+			return "", err
+		}
+		err = switchWorkTree(gitRepoPath, workTree, oldTree, commit.treeHash, true, formatter, reader, store)
+		if err != nil {
+			return "", err
+		}
+		//files that were only added to the index are discarded too
+		for _, path := range status.StagedNew {
+			if _, ok := blobs[path]; !ok {
+				err = removeWorkTreeFile(workTree, path)
+				if err != nil {
+					return "", err
+				}
+			}
+		}
+		//every tracked file matches the target now
+		err = writeIndexFromTree(gitRepoPath, workTree, blobs)
+		if err != nil {
+			return "", err
+		}
+	case MixedReset:
+		err = resetIndex(gitRepoPath, blobs)
+		if err != nil {
+			return "", err
+		}
+	}
+	if mode != SoftReset && repository.Exists(mergeHeadPath) {
+		err = os.Remove(mergeHeadPath)
+		if err != nil {
+			return "", err
+		}
+	}
+	return target, moveHead(
+		gitRepoPath,
+		head,
+		target,
+		fmt.Sprintf("reset: moving to %s", revision),
+		reader,
+		store,
+	)
+}
+
+//Split arguments of reset into a revision and paths like git does
+//All arguments are paths if they follow "--", otherwise the first one is a revision
+//unless it can't be resolved and a file with such name exists.
+//An empty revision means HEAD
+func ResetArguments(
+	gitRepoPath string,
+	args []string,
+	separated bool,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (string, []string) {
+	if separated || len(args) == 0 {
+		return "", args
+	}
+	_, err := ResolveRevision(gitRepoPath, args[0], reader, store)
+	if err != nil && workTreeExists(args[0]) {
+		return "", args
+	}
+	return args[0], args[1:]
+}
+
+//Restore index entries of given paths from a revision, HEAD by default
+//Paths absent from the revision are removed from the index,
+//the working tree and the current branch are not touched.
+//Returns paths which entries were reset
+func ResetPaths(
+	gitRepoPath string,
+	workTree string,
+	revision string,
+	paths []string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) ([]string, error) {
+	var treeHash repository.Hash
+	var err error
+	if len(revision) == 0 {
+		//a repository without commits resets to an empty tree
+		treeHash, err = headTree(gitRepoPath, reader, store)
+	} else {
+		treeHash, err = ResolveRevision(gitRepoPath, revision+"^{tree}", reader, store)
+	}
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := flattenTree(treeHash, store)
+	if err != nil {
+		return nil, err
+	}
+	indexPath := repository.IndexPath(gitRepoPath)
+	idx, err := index.Read(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	entries := idx.Entries()
+	byPath := make(map[string]index.Entry)
+	for _, entry := range entries {
+		byPath[entry.Path()] = entry
+	}
+	matched := make(map[string]bool)
+	for _, path := range paths {
+		relative, err := relativePath(workTree, path)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for candidate := range byPath {
+			if pathMatches(candidate, relative) {
+				matched[candidate] = true
+				found = true
+			}
+		}
+		for candidate := range blobs {
+			if pathMatches(candidate, relative) {
+				matched[candidate] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.New(
+				fmt.Sprintf("pathspec '%s' did not match any files", path),
+			)
+		}
+	}
+	var reset []string
+	for path := range matched {
+		blob, inTree := blobs[path]
+		entry, inIndex := idx.Get(path)
+		switch {
+		case !inTree:
+			idx.Remove(path)
+		case inIndex && entry.Hash() == blob.hash && entry.Mode() == blob.mode:
+			continue
+		default:
+			//empty stat makes status compare the content with the working tree
+			idx.Add(index.NewEntry(path, blob.mode, blob.hash, index.Stat{}))
+		}
+		reset = append(reset, path)
+	}
+	sort.Strings(reset)
+	return reset, idx.Write(indexPath)
+}
+
+//Rebuild the index from blobs of a tree
+//Entries that don't change keep their stat data,
+//other entries have to be compared with the working tree by content
+func resetIndex(gitRepoPath string, blobs map[string]treeEntry) error {
+	indexPath := repository.IndexPath(gitRepoPath)
+	idx, err := index.Read(indexPath)
+	if err != nil {
+		return err
+	}
+	var entries []index.Entry
+	for path, blob := range blobs {
+		entry, ok := idx.Get(path)
+		if ok && entry.Hash() == blob.hash && entry.Mode() == blob.mode {
+			entries = append(entries, entry)
+			continue
+		}
+		entries = append(entries, index.NewEntry(path, blob.mode, blob.hash, index.Stat{}))
+	}
+	//the index that was read is replaced, so a concurrent change of the file is detected
+	idx.Clear()
+	idx.Add(entries...)
+	return idx.Write(indexPath)
+}
+
+//check if a path is given one or lies inside of it
+func pathMatches(path string, pattern string) bool {
+	return pattern == "." || path == pattern || strings.HasPrefix(path, pattern+"/")
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestReset(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	write := func(name string, content string) {
+		err := os.WriteFile(dir+"/"+name, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	status := func() string {
+		status, err := GitStatus(gitDir, dir, &formatter, repository.Reader, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return status.Porcelain()
+	}
+	reset := func(revision string, mode ResetMode) {
+		_, err := Reset(gitDir, dir, revision, mode, &formatter, repository.Reader, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("file", "first")
+	first := commitFiles(t, gitDir, "First", dir+"/file")
+	write("file", "second")
+	write("added", "added")
+	second := commitFiles(t, gitDir, "Second", dir+"/file", dir+"/added")
+	reset("HEAD~1", SoftReset)
+	head, err := headCommit(gitDir, repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != first {
+		t.Fatalf("Expected branch at %s, got %s", first, head)
+	}
+	if status() != "A  added\nM  file\n" {
+		t.Fatalf("Soft reset should keep changes staged\n%s", status())
+	}
+	reset("", MixedReset)
+	if status() != " M file\n?? added\n" {
+		t.Fatalf("Mixed reset should unstage changes\n%s", status())
+	}
+	//path limited reset only touches the index
+	_, err = Add(gitDir, dir, []string{dir + "/file", dir + "/added"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, err := ResetPaths(gitDir, dir, "", []string{dir + "/file"}, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, ",") != "file" || status() != "A  added\n M file\n" {
+		t.Fatalf("Only file should be unstaged %v\n%s", paths, status())
+	}
+	_, err = ResetPaths(gitDir, dir, "", []string{dir + "/missing"}, repository.Reader, store)
+	if err == nil {
+		t.Fatal("Unknown path was reset")
+	}
+	//restore an entry from an older commit
+	paths, err = ResetPaths(gitDir, dir, string(second), []string{dir}, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, ",") != "file" || status() != "A  added\nM  file\n" {
+		t.Fatalf("Index should match the second commit %v\n%s", paths, status())
+	}
+	_, err = Reset(gitDir, dir, "", HardReset, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status() != "" {
+		t.Fatalf("Hard reset should discard all changes\n%s", status())
+	}
+	if workTreeExists(dir + "/added") {
+		t.Fatal("Hard reset should remove files that were only staged")
+	}
+	//recover the commit lost by the soft reset
+	_, err = Reset(gitDir, dir, "master@{3}", HardReset, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(dir + "/added")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "added" || status() != "" {
+		t.Fatalf("Working tree should match the second commit\n%s", status())
+	}
+	head, err = headCommit(gitDir, repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != second {
+		t.Fatalf("Expected branch at %s, got %s", second, head)
+	}
+}
+
+func TestResetArguments(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	err = os.WriteFile(dir+"/file", []byte("first"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, gitDir, "First", dir+"/file")
+	file := dir + "/file"
+	for _, test := range []struct {
+		args      []string
+		separated bool
+		revision  string
+		paths     []string
+	}{
+		//reset -- file
+		{[]string{file}, true, "", []string{file}},
+		//reset file
+		{[]string{file}, false, "", []string{file}},
+		//reset HEAD -- file
+		{[]string{"HEAD", file}, false, "HEAD", []string{file}},
+		//reset HEAD
+		{[]string{"HEAD"}, false, "HEAD", []string{}},
+		//reset -- HEAD, a file named like a revision
+		{[]string{"HEAD"}, true, "", []string{"HEAD"}},
+	} {
+		revision, paths := ResetArguments(gitDir, test.args, test.separated, repository.Reader, store)
+		if revision != test.revision || strings.Join(paths, ",") != strings.Join(test.paths, ",") {
+			t.Fatalf("Expected revision '%s' and paths %v for %v, got '%s' and %v", test.revision, test.paths, test.args, revision, paths)
+		}
+	}
+	//both forms unstage the file
+	err = os.WriteFile(dir+"/file", []byte("second"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, separated := range []bool{true, false} {
+		_, err = Add(gitDir, dir, []string{file}, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revision, paths := ResetArguments(gitDir, []string{file}, separated, repository.Reader, store)
+		reset, err := ResetPaths(gitDir, dir, revision, paths, repository.Reader, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reset) != 1 || reset[0] != "file" {
+			t.Fatalf("Expected the file to be unstaged, got %v", reset)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//Resolve a revision expression to an object hash
+//Supported forms:
+//  HEAD or @           - the current commit
+//  v1.0                - a tag, an annotated tag resolves to the tag object
+//  master              - the tip of a branch
+//  e83c5163, e83c...   - a full or an abbreviated hash
+//  @{-1}               - the previously checked out branch or commit
+//  master@{2}, @{1}    - the value a branch (the current one by default) had n moves ago
+//  <rev>~3             - the third first-parent ancestor
+//  <rev>^2             - the second parent, <rev>^0 is the commit itself
+//  <rev>^{tree}        - the tree of a commit, ^{commit} and ^{tag} check the type
+//  <rev>^{}            - the object an annotated tag points to
+//Tags are peeled to commits before ancestry operators are applied
+//Operators can be chained, e.g. "master~2^2^{tree}"
+func ResolveRevision(
+	gitRepoPath string,
+	revision string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	base := revision
+	operators := ""
+	if index := strings.IndexAny(revision, "~^"); index != -1 {
+		base, operators = revision[:index], revision[index:]
+	}
+	hash, err := resolveRevisionBase(gitRepoPath, base, reader, store)
+	if err != nil {
+		return "", err
+	}
+	for len(operators) != 0 {
+		operator := operators[0]
+		operators = operators[1:]
+		if operator == '^' && strings.HasPrefix(operators, "{") {
+			end := strings.Index(operators, "}")
+			if end == -1 {
+				return "", unknownRevision(revision)
+			}
+			hash, err = peelRevision(hash, operators[1:end], store)
+			if err != nil {
+				return "", err
+			}
+			operators = operators[end+1:]
+			continue
+		}
+		digits := len(operators) - len(strings.TrimLeft(operators, "0123456789"))
+		n := 1
+		if digits != 0 {
+			n, err = strconv.Atoi(operators[:digits])
+			if err != nil {
+				return "", unknownRevision(revision)
+			}
+			operators = operators[digits:]
+		}
+		if operator == '~' {
+			for i := 0; i < n; i++ {
+				hash, err = nthParent(hash, 1, revision, store)
+				if err != nil {
+					return "", err
+				}
+			}
+		} else {
+			hash, err = nthParent(hash, n, revision, store)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return hash, nil
+}
+
+//Resolve a revision and peel annotated tags down to a commit
+func ResolveCommit(
+	gitRepoPath string,
+	revision string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	hash, err := ResolveRevision(gitRepoPath, revision, reader, store)
+	if err != nil {
+		return "", err
+	}
+	return peelRevision(hash, repository.COMMIT, store)
+}
+
+//Resolve "@{-n}" and its "-" shortcut to a name of a previously checked out branch
+//or to a hash of a previously detached commit, any other name is returned as is
+func ResolveBranchName(
+	gitRepoPath string,
+	name string,
+	reader repository.FileReader,
+) (string, error) {
+	if name == "-" {
+		name = "@{-1}"
+	}
+	n, ok := previousBranchIndex(name)
+	if !ok {
+		return name, nil
+	}
+	return previousBranch(gitRepoPath, n, reader)
+}
+
+//a revision without operators
+func resolveRevisionBase(
+	gitRepoPath string,
+	base string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	if base == "HEAD" || base == "@" {
+		return headCommit(gitRepoPath, reader)
+	}
+	if n, ok := previousBranchIndex(base); ok {
+		previous, err := previousBranch(gitRepoPath, n, reader)
+		if err != nil {
+			return "", err
+		}
+		//a detached HEAD is logged as a commit hash
+		return resolveRevisionBase(gitRepoPath, previous, reader, store)
+	}
+	if ref, n, ok := reflogIndex(base); ok {
+		if len(ref) == 0 {
+			branch, err := currentBranch(gitRepoPath, reader)
+			if err != nil {
+				return "", err
+			}
+			ref = branch
+		}
+		return reflogValue(gitRepoPath, ReflogRef(ref), n, reader)
+	}
+	//tags take precedence over branches like in git
+	if repository.ValidateRefName(base) == nil {
+		if repository.Exists(repository.TagsPath(gitRepoPath) + base) {
+			return tagTarget(gitRepoPath, base, reader)
+		}
+		if repository.Exists(repository.HeadsPath(gitRepoPath) + base) {
+			return branchTip(gitRepoPath, base, reader)
+		}
+	}
+	if hash, err := repository.NewHash(base); err == nil && store.Has(hash) {
+		return hash, nil
+	}
+	if len(base) >= repository.MinHashPrefix {
+		return repository.ExpandHash(store, base)
+	}
+	return "", unknownRevision(base)
+}
+
+//parse "@{-n}", returns false if given string has a different form
+func previousBranchIndex(revision string) (int, bool) {
+	if !strings.HasPrefix(revision, "@{-") || !strings.HasSuffix(revision, "}") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(revision[3 : len(revision)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+//parse "<ref>@{n}", the ref is empty for "@{n}"
+//returns false if given string has a different form
+func reflogIndex(revision string) (string, int, bool) {
+	start := strings.LastIndex(revision, "@{")
+	if start == -1 || !strings.HasSuffix(revision, "}") {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(revision[start+2 : len(revision)-1])
+	if err != nil || n < 0 {
+		return "", 0, false
+	}
+	return revision[:start], n, true
+}
+
+//n-th parent of a commit, the zeroth parent is the commit itself
+func nthParent(
+	hash repository.Hash,
+	n int,
+	revision string,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	hash, err := peelRevision(hash, repository.COMMIT, store)
+	if err != nil {
+		return "", err
+	}
+	commit, err := readCommit(hash, store)
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return hash, nil
+	}
+	if n > len(commit.parentHashes) {
+		return "", errors.New(
+			fmt.Sprintf(
+				"Revision '%s' doesn't exist, commit %s has %d parent(s)",
+				revision,
+				hash,
+				len(commit.parentHashes),
+			),
+		)
+	}
+	return commit.parentHashes[n-1], nil
+}
+
+//"^{type}" operator
+//Tags are followed until an object of requested type is found,
+//an empty type means the first object that is not a tag
+func peelRevision(
+	hash repository.Hash,
+	objType string,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	if objType == repository.TAG {
+		deser, err := store.Get(hash)
+		if err != nil {
+			return "", err
+		}
+		if deser.ObjType != repository.TAG {
+			return "", errors.New(
+				fmt.Sprintf("Object %s is a %s, not a tag", hash, deser.ObjType),
+			)
+		}
+		return hash, nil
+	}
+	peeled, peeledType, err := peelTag(hash, store)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(objType) == 0 || objType == string(peeledType):
+		return peeled, nil
+	case objType == repository.TREE && peeledType == repository.COMMIT:
+		commit, err := readCommit(peeled, store)
+		if err != nil {
+			return "", err
+		}
+		return commit.treeHash, nil
+	case objType == repository.COMMIT || objType == repository.TREE:
+		return "", errors.New(
+			fmt.Sprintf("Object %s is a %s, not a %s", hash, peeledType, objType),
+		)
+	default:
+		return "", errors.New(
+			fmt.Sprintf("Object %s can't be peeled to '%s'", hash, objType),
+		)
+	}
+}
+
+func unknownRevision(revision string) error {
+	return errors.New(
+		fmt.Sprintf("unknown revision '%s'", revision),
+	)
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestResolveRevision(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	write := func(name string, content string) {
+		err := os.WriteFile(dir+"/"+name, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("file", "first")
+	first := commitFiles(t, gitDir, "First", dir+"/file")
+	write("file", "second")
+	second := commitFiles(t, gitDir, "Second", dir+"/file")
+	err = writeBranch(gitDir, "feature", "", second, "branch: Created from HEAD", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switchBranch(t, gitDir, "feature")
+	write("other", "feature")
+	feature := commitFiles(t, gitDir, "Feature", dir+"/other")
+	switchBranch(t, gitDir, "master")
+	write("file", "third")
+	third := commitFiles(t, gitDir, "Third", dir+"/file")
+	merge := mergeBranch(t, gitDir, "feature").Commit
+	mergeCommit, err := readCommit(merge, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := func(revision string) (repository.Hash, error) {
+		return ResolveRevision(gitDir, revision, repository.Reader, store)
+	}
+	for revision, expected := range map[string]repository.Hash{
+		"HEAD":              merge,
+		"@":                 merge,
+		"master":            merge,
+		"feature":           feature,
+		string(second):      second,
+		string(third)[0:7]:  third,
+		"HEAD^":             third,
+		"HEAD^1":            third,
+		"HEAD^2":            feature,
+		"HEAD^0":            merge,
+		"HEAD~2":            second,
+		"master~3":          first,
+		"HEAD^2~1":          second,
+		"HEAD^^^":           first,
+		"HEAD^{commit}":     merge,
+		"HEAD^{tree}":       mergeCommit.treeHash,
+		"HEAD^{tree}^{}":    mergeCommit.treeHash,
+		"@{-1}":             feature,
+		"feature~1^{tree}^": "",
+	} {
+		hash, err := resolve(revision)
+		if len(expected) == 0 {
+			if err == nil {
+				t.Errorf("Revision %s was resolved to %s", revision, hash)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Failed to resolve %s: %s", revision, err)
+			continue
+		}
+		if hash != expected {
+			t.Errorf("Revision %s expected %s, got %s", revision, expected, hash)
+		}
+	}
+	for _, revision := range []string{"HEAD~4", "HEAD^3", "unknown", "HEAD^{blob}", "~1", "@{-3}"} {
+		_, err = resolve(revision)
+		if err == nil {
+			t.Errorf("Revision %s was resolved", revision)
+		}
+	}
+	//"-" is a shortcut for the previous branch
+	branch, err := ResolveBranchName(gitDir, "-", repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "feature" {
+		t.Fatalf("Expected previous branch feature, got %s", branch)
+	}
+}
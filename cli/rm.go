@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//Remove files from the index and the working tree
+//cached - keep files in the working tree
+//recursive - allow removal of directories
+//force - skip the check that files don't have uncommitted changes
+func Remove(
+	gitRepoPath string,
+	workTree string,
+	paths []string,
+	cached bool,
+	recursive bool,
+	force bool,
+	formatter repository.GitFileFormatter,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) ([]string, error) {
+	indexPath := repository.IndexPath(gitRepoPath)
+	idx, err := index.Read(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	entries := idx.Entries()
+	var matched []index.Entry
+	for _, path := range paths {
+		relative, err := relativePath(workTree, path)
+		if err != nil {
+			return nil, err
+		}
+		found, err := matchIndexEntries(entries, relative, recursive)
+		if err != nil {
+			return nil, err
+		}
+		matched = append(matched, found...)
+	}
+	if !force {
+		err = checkRemovable(gitRepoPath, workTree, matched, cached, formatter, reader, store)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var removed []string
+	seen := make(map[string]bool)
+	for _, entry := range matched {
+		if !seen[entry.Path()] {
+			seen[entry.Path()] = true
+			removed = append(removed, entry.Path())
+		}
+	}
+	idx.Remove(removed...)
+	err = idx.Write(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		for _, path := range removed {
+			err = removeWorkTreeFile(workTree, path)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+//find index entries for a path relative to the working tree
+//a directory matches all entries inside of it
+func matchIndexEntries(
+	entries []index.Entry,
+	path string,
+	recursive bool,
+) ([]index.Entry, error) {
+	prefix := path + string(os.PathSeparator)
+	var found []index.Entry
+	isDir := false
+	for _, entry := range entries {
+		switch {
+		case entry.Path() == path:
+			found = append(found, entry)
+		case path == "." || strings.HasPrefix(entry.Path(), prefix):
+			isDir = true
+			found = append(found, entry)
+		}
+	}
+	if len(found) == 0 {
+		return nil, errors.New(
+			fmt.Sprintf("pathspec '%s' did not match any files", path),
+		)
+	}
+	if isDir && !recursive {
+		return nil, errors.New(
+			fmt.Sprintf("not removing '%s' recursively without -r", path),
+		)
+	}
+	return found, nil
+}
+
+//make sure that removal doesn't lose changes which are not committed
+func checkRemovable(
+	gitRepoPath string,
+	workTree string,
+	entries []index.Entry,
+	cached bool,
+	formatter repository.GitFileFormatter,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) error {
+	treeHash, err := headTree(gitRepoPath, reader, store)
+	if err != nil {
+		return err
+	}
+	headBlobs, err := flattenTree(
+		treeHash,
+		store,
+	)
+	if err != nil {
+		return err
+	}
+	indexTime, err := indexModificationTime(gitRepoPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		headBlob, ok := headBlobs[entry.Path()]
+		staged := !ok || headBlob.hash != entry.Hash() || headBlob.mode != entry.Mode()
+		file := filepath.Join(workTree, entry.Path())
+		exists := workTreeExists(file)
+		changed := false
+		if exists {
+			changed, err = workTreeChanged(file, entry, indexTime, formatter)
+			if err != nil {
+				return err
+			}
+		}
+		//with cached the staged content is lost only if the working tree doesn't have it either
+		if cached {
+			if staged && (!exists || changed) {
+				return errors.New(
+					fmt.Sprintf(
+						"'%s' has staged content different from both the file and the HEAD (use -f to force removal)",
+						entry.Path(),
+					),
+				)
+			}
+			continue
+		}
+		if staged {
+			return errors.New(
+				fmt.Sprintf(
+					"'%s' has changes staged in the index (use -f to force removal)",
+					entry.Path(),
+				),
+			)
+		}
+		if changed {
+			return errors.New(
+				fmt.Sprintf(
+					"'%s' has local modifications (use -f to force removal)",
+					entry.Path(),
+				),
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestRemove(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	remove := func(paths []string, cached bool, recursive bool, force bool) ([]string, error) {
+		return Remove(
+			gitDir,
+			dir,
+			paths,
+			cached,
+			recursive,
+			force,
+			&formatter,
+			repository.Reader,
+			store,
+		)
+	}
+	err = os.MkdirAll(dir+"/dir", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"/dir/first", "/dir/second", "/kept", "/modified"} {
+		err = os.WriteFile(dir+file, []byte(file), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	commitFiles(t, gitDir, "Files", dir+"/dir", dir+"/kept", dir+"/modified")
+	_, err = remove([]string{dir + "/dir"}, false, false, false)
+	if err == nil {
+		t.Fatal("Directory should not be removed without -r")
+	}
+	removed, err := remove([]string{dir + "/dir"}, false, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || repository.Exists(dir+"/dir") {
+		t.Fatalf("Directory should be removed, got %v", removed)
+	}
+	_, err = remove([]string{dir + "/kept"}, true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !repository.Exists(dir + "/kept") {
+		t.Fatal("File removed with --cached should stay in the working tree")
+	}
+	err = os.WriteFile(dir+"/modified", []byte("changed"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = remove([]string{dir + "/modified"}, false, false, false)
+	if err == nil {
+		t.Fatal("File with local modifications should not be removed without -f")
+	}
+	_, err = remove([]string{dir + "/modified"}, false, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a file that was just added is kept by the working tree
+	err = os.WriteFile(dir+"/new", []byte("new"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Add(gitDir, dir, []string{dir + "/new"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = remove([]string{dir + "/new"}, false, false, false)
+	if err == nil {
+		t.Fatal("File with staged changes should not be removed without -f")
+	}
+	_, err = remove([]string{dir + "/new"}, true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !repository.Exists(dir + "/new") {
+		t.Fatal("File removed with --cached should stay in the working tree")
+	}
+	//staged content that is neither in HEAD nor in the working tree
+	_, err = Add(gitDir, dir, []string{dir + "/new"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/new", []byte("changed after add"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = remove([]string{dir + "/new"}, true, false, false)
+	if err == nil {
+		t.Fatal("Staged content different from both the file and HEAD should not be removed without -f")
+	}
+	_, err = remove([]string{dir + "/new"}, true, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := index.Read(repository.IndexPath(gitDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := idx.Entries()
+	if len(entries) != 0 {
+		t.Fatalf("Index should be empty, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//state of files in HEAD, index and working tree
+type Status struct {
+	Branch         string          //empty if HEAD is detached
+	Detached       repository.Hash //commit of a detached HEAD
+	StagedNew      []string        //in index but not in HEAD
+	StagedModified []string        //index differs from HEAD
+	StagedDeleted  []string        //in HEAD but not in index
+	Modified       []string        //working tree differs from index
+	Deleted        []string        //in index but not in working tree
+	Untracked      []string        //in working tree but not in index
+	Unmerged       []string        //has conflict stages in index after a merge
+}
+
+//Compare HEAD tree with index and index with working tree
+func GitStatus(
+	gitRepoPath string,
+	workTree string,
+	formatter repository.GitFileFormatter,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (*Status, error) {
+	branch, detached, err := readHead(gitRepoPath, reader)
+	if err != nil {
+		return nil, err
+	}
+	treeHash, err := headTree(gitRepoPath, reader, store)
+	if err != nil {
+		return nil, err
+	}
+	headEntries, err := flattenTree(treeHash, store)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := index.Read(repository.IndexPath(gitRepoPath))
+	if err != nil {
+		return nil, err
+	}
+	indexEntries := idx.Entries()
+	status := &Status{Branch: branch, Detached: detached}
+	indexed := make(map[string]bool)
+	//versions of a conflicting path are not compared with HEAD and the working tree
+	var merged []index.Entry
+	for _, entry := range indexEntries {
+		if entry.Stage() != index.Merged {
+			if !indexed[entry.Path()] {
+				status.Unmerged = append(status.Unmerged, entry.Path())
+			}
+			indexed[entry.Path()] = true
+			continue
+		}
+		merged = append(merged, entry)
+		indexed[entry.Path()] = true
+		headEntry, ok := headEntries[entry.Path()]
+		if !ok {
+			status.StagedNew = append(status.StagedNew, entry.Path())
+		} else if headEntry.hash != entry.Hash() || headEntry.mode != entry.Mode() {
+			status.StagedModified = append(status.StagedModified, entry.Path())
+		}
+	}
+	for path := range headEntries {
+		if !indexed[path] {
+			status.StagedDeleted = append(status.StagedDeleted, path)
+		}
+	}
+	indexTime, err := indexModificationTime(gitRepoPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range merged {
+		file := filepath.Join(workTree, entry.Path())
+		if !workTreeExists(file) {
+			status.Deleted = append(status.Deleted, entry.Path())
+			continue
+		}
+		changed, err := workTreeChanged(file, entry, indexTime, formatter)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			status.Modified = append(status.Modified, entry.Path())
+		}
+	}
+	err = walkWorkTree(
+		workTree,
+		gitRepoPath,
+		workTree,
+		func(file string, info os.FileInfo) error {
+			path, err := relativePath(workTree, file)
+			if err != nil {
+				return err
+			}
+			if !indexed[path] {
+				status.Untracked = append(status.Untracked, path)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(status.StagedDeleted)
+	sort.Strings(status.Untracked)
+	return status, nil
+}
+
+//modification time of the index file, zero if index doesn't exist
+func indexModificationTime(gitRepoPath string) (int64, error) {
+	indexPath := repository.IndexPath(gitRepoPath)
+	if !repository.Exists(indexPath) {
+		return 0, nil
+	}
+	stat, err := index.StatFile(indexPath)
+	return int64(stat.MTime), err
+}
+
+//check if a file in the working tree differs from its index entry
+//the file is rehashed only if its stat data doesn't match the index
+//or if it was modified in the same second the index was written
+func workTreeChanged(
+	file string,
+	entry index.Entry,
+	indexTime int64,
+	formatter repository.GitFileFormatter,
+) (bool, error) {
+	info, err := os.Lstat(file)
+	if err != nil {
+		return false, err
+	}
+	if repository.FileMode(info) != entry.Mode() {
+		return true, nil
+	}
+	stat, err := index.StatFile(file)
+	if err != nil {
+		return false, err
+	}
+	if entry.Stat().Matches(stat) && int64(stat.MTime) < indexTime {
+		return false, nil
+	}
+	content, _, err := readWorkTreeFile(file)
+	if err != nil {
+		return false, err
+	}
+	blob, err := formatter.Serialize(content, repository.BLOB)
+	if err != nil {
+		return false, err
+	}
+	return blob.Hash != entry.Hash(), nil
+}
+
+//true if nothing is staged and the working tree matches the index
+//untracked files are not taken into account
+func (s *Status) Clean() bool {
+	return len(s.StagedNew) == 0 &&
+		len(s.StagedModified) == 0 &&
+		len(s.StagedDeleted) == 0 &&
+		len(s.Modified) == 0 &&
+		len(s.Deleted) == 0 &&
+		len(s.Unmerged) == 0
+}
+
+//Machine readable status, one file per line
+//Example: "AM src/main.go", "?? build.log"
+func (s *Status) Porcelain() string {
+	codes := make(map[string][]byte)
+	mark := func(paths []string, index int, code byte) {
+		for _, path := range paths {
+			if _, ok := codes[path]; !ok {
+				codes[path] = []byte("  ")
+			}
+			codes[path][index] = code
+		}
+	}
+	mark(s.StagedNew, 0, 'A')
+	mark(s.StagedModified, 0, 'M')
+	mark(s.StagedDeleted, 0, 'D')
+	mark(s.Modified, 1, 'M')
+	mark(s.Deleted, 1, 'D')
+	mark(s.Unmerged, 0, 'U')
+	mark(s.Unmerged, 1, 'U')
+	var paths []string
+	for path := range codes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	builder := strings.Builder{}
+	for _, path := range paths {
+		builder.WriteString(fmt.Sprintf("%s %s\n", codes[path], path))
+	}
+	for _, path := range s.Untracked {
+		builder.WriteString(fmt.Sprintf("?? %s\n", path))
+	}
+	return builder.String()
+}
+
+func (s *Status) String() string {
+	builder := strings.Builder{}
+	if len(s.Detached) != 0 {
+		builder.WriteString(fmt.Sprintf("HEAD detached at %s\n", string(s.Detached)[0:7]))
+	} else {
+		builder.WriteString(fmt.Sprintf("On branch %s\n", s.Branch))
+	}
+	if len(s.StagedNew)+len(s.StagedModified)+len(s.StagedDeleted) != 0 {
+		builder.WriteString("Changes to be committed:\n")
+		writeStatusPaths(&builder, "new file:", s.StagedNew)
+		writeStatusPaths(&builder, "modified:", s.StagedModified)
+		writeStatusPaths(&builder, "deleted:", s.StagedDeleted)
+	}
+	if len(s.Unmerged) != 0 {
+		builder.WriteString("Unmerged paths:\n")
+		writeStatusPaths(&builder, "unmerged:", s.Unmerged)
+	}
+	if len(s.Modified)+len(s.Deleted) != 0 {
+		builder.WriteString("Changes not staged for commit:\n")
+		writeStatusPaths(&builder, "modified:", s.Modified)
+		writeStatusPaths(&builder, "deleted:", s.Deleted)
+	}
+	if len(s.Untracked) != 0 {
+		builder.WriteString("Untracked files:\n")
+		writeStatusPaths(&builder, "", s.Untracked)
+	}
+	if s.Clean() && len(s.Untracked) == 0 {
+		builder.WriteString("nothing to commit, working tree clean\n")
+	}
+	return builder.String()
+}
+
+func writeStatusPaths(builder *strings.Builder, label string, paths []string) {
+	for _, path := range paths {
+		if len(label) == 0 {
+			builder.WriteString(fmt.Sprintf("\t%s\n", path))
+		} else {
+			builder.WriteString(fmt.Sprintf("\t%-12s%s\n", label, path))
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestGitStatus(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	formatter := repository.DefaultGitFileFormatter{}
+	err = os.WriteFile(dir+"/staged", []byte("staged"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/modified", []byte("before"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/untracked", []byte("untracked"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Add(gitDir, dir, []string{dir + "/staged", dir + "/modified"}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/modified", []byte("after"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := GitStatus(gitDir, dir, &formatter, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Branch != repository.DefaultBranch {
+		t.Fatalf("Wrong branch, '%s' expected, got '%s'", repository.DefaultBranch, status.Branch)
+	}
+	expected := "AM modified\nA  staged\n?? untracked\n"
+	if status.Porcelain() != expected {
+		t.Fatalf("Wrong porcelain status, '%s' expected, got '%s'", expected, status.Porcelain())
+	}
+	if len(status.Modified) != 1 || status.Modified[0] != "modified" {
+		t.Fatalf("File 'modified' should be reported as modified, got %v", status.Modified)
+	}
+	if status.Clean() {
+		t.Fatal("Status with staged files can't be clean")
+	}
+}
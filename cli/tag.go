@@ -0,0 +1,320 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//an annotated tag object
+type Tag struct {
+	object  repository.Hash //hash of a tagged object
+	objType repository.GitObjectType
+	name    string
+	message string
+	user    *User
+	time    *Time
+}
+
+//Create a tag that points to given target, an empty target means HEAD
+//With a message an annotated tag object is created and the ref points to it,
+//otherwise a lightweight tag that points to the target directly is created
+func CreateTag(
+	gitRepoPath string,
+	name string,
+	target string,
+	message string,
+	force bool,
+	user *User,
+	time *Time,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) (repository.Hash, error) {
+	err := repository.ValidateRefName(name)
+	if err != nil {
+		return "", err
+	}
+	pathToTag := repository.TagsPath(gitRepoPath) + name
+	if !force && repository.Exists(pathToTag) {
+		return "", errors.New(
+			fmt.Sprintf("tag '%s' already exists", name),
+		)
+	}
+	if len(target) == 0 {
+		target = "HEAD"
+	}
+	hash, err := ResolveRevision(gitRepoPath, target, reader, store)
+	if err != nil {
+		return "", err
+	}
+	if len(message) != 0 {
+		deser, err := store.Get(hash)
+		if err != nil {
+			return "", err
+		}
+		hash, err = store.Put(
+			repository.TAG,
+			createTagObject(
+				Tag{
+					object:  hash,
+					objType: deser.ObjType,
+					name:    name,
+					message: message,
+					user:    user,
+					time:    time,
+				},
+			),
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+	if force {
+		return hash, repository.WriteLocked(pathToTag, []byte(hash), 0755)
+	}
+	//another process may have created the tag in the meantime
+	return hash, repository.CompareAndSwap(pathToTag, nil, []byte(hash), 0755)
+}
+
+//Names of all tags sorted alphabetically
+func ListTags(gitRepoPath string) ([]string, error) {
+	tagsPath := repository.TagsPath(gitRepoPath)
+	var tags []string
+	//repositories created before tags were supported have no tags directory
+	if !repository.Exists(tagsPath) {
+		return tags, nil
+	}
+	err := filepath.Walk(
+		tagsPath,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			//lock of a ref that is being written by another process
+			if repository.IsLockFile(path) {
+				return nil
+			}
+			name, err := filepath.Rel(tagsPath, path)
+			if err != nil {
+				return err
+			}
+			tags = append(tags, name)
+			return nil
+		},
+	)
+	sort.Strings(tags)
+	return tags, err
+}
+
+//Delete a tag, returns a hash the tag pointed to
+func DeleteTag(
+	gitRepoPath string,
+	name string,
+	reader repository.FileReader,
+) (repository.Hash, error) {
+	err := repository.ValidateRefName(name)
+	if err != nil {
+		return "", err
+	}
+	hash, err := tagTarget(gitRepoPath, name, reader)
+	if err != nil {
+		return "", err
+	}
+	return hash, removeWorkTreeFile(repository.TagsPath(gitRepoPath), name)
+}
+
+//Print a tag: the header and the message of an annotated tag
+//followed by the header of a commit it points to
+func ShowTag(
+	writer io.Writer,
+	gitRepoPath string,
+	name string,
+	reader repository.FileReader,
+	store repository.ObjectStore,
+) error {
+	err := repository.ValidateRefName(name)
+	if err != nil {
+		return err
+	}
+	hash, err := tagTarget(gitRepoPath, name, reader)
+	if err != nil {
+		return err
+	}
+	for {
+		deser, err := store.Get(hash)
+		if err != nil {
+			return err
+		}
+		switch deser.ObjType {
+		case repository.TAG:
+			tag, err := parseTag(deser.Content)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(
+				writer,
+				"tag %s\nTagger: %s\nDate:   %s\n\n%s\n\n",
+				tag.name,
+				tag.user.String(),
+				tag.time.String(),
+				strings.TrimRight(tag.message, "\n"),
+			)
+			if err != nil {
+				return err
+			}
+			hash = tag.object
+		case repository.COMMIT:
+			commit, err := parseCommit(deser.Content)
+			if err != nil {
+				return err
+			}
+			return writeCommitHeader(writer, hash, "", commit)
+		default:
+			_, err = fmt.Fprintf(writer, "%s %s\n", deser.ObjType, hash)
+			return err
+		}
+	}
+}
+
+//hash stored in a tag ref
+func tagTarget(
+	gitRepoPath string,
+	name string,
+	reader repository.FileReader,
+) (repository.Hash, error) {
+	pathToTag := repository.TagsPath(gitRepoPath) + name
+	if !repository.Exists(pathToTag) {
+		return "", errors.New(
+			fmt.Sprintf("tag '%s' not found", name),
+		)
+	}
+	content, err := reader(pathToTag)
+	if err != nil {
+		return "", err
+	}
+	return repository.NewHash(strings.TrimSpace(string(content)))
+}
+
+//Follow tag objects until an object of a different type is found
+func peelTag(
+	hash repository.Hash,
+	store repository.ObjectStore,
+) (repository.Hash, repository.GitObjectType, error) {
+	for {
+		deser, err := store.Get(hash)
+		if err != nil {
+			return "", "", err
+		}
+		if deser.ObjType != repository.TAG {
+			return hash, deser.ObjType, nil
+		}
+		tag, err := parseTag(deser.Content)
+		if err != nil {
+			return "", "", err
+		}
+		hash = tag.object
+	}
+}
+
+// +------------------------------+
+// | Tag format line by line      |
+// +------------------------------+
+// | object hash                  |
+// | type of the tagged object    |
+// | tag name                     |
+// | tagger                       |
+// | empty line                   |
+// | tag message                  |
+// +------------------------------+
+func createTagObject(tag Tag) []byte {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("object %s\n", tag.object))
+	builder.WriteString(fmt.Sprintf("type %s\n", tag.objType))
+	builder.WriteString(fmt.Sprintf("tag %s\n", tag.name))
+	builder.WriteString(
+		fmt.Sprintf(
+			"tagger %s <%s> %d %s\n",
+			tag.user.Name,
+			tag.user.Email,
+			tag.time.unixSeconds,
+			tag.time.zone,
+		),
+	)
+	builder.WriteString("\n")
+	builder.WriteString(fmt.Sprintf("%s\n", tag.message))
+	return []byte(builder.String())
+}
+
+func parseTag(content string) (*Tag, error) {
+	parts := strings.SplitN(content, "\n\n", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("Invalid tag object, there is no message")
+	}
+	tag := &Tag{message: parts[1]}
+	for _, line := range strings.Split(parts[0], "\n") {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		key, value := fields[0], fields[1]
+		switch key {
+		case "object":
+			hash, err := repository.NewHash(value)
+			if err != nil {
+				return nil, err
+			}
+			tag.object = hash
+		case "type":
+			objType, err := repository.AsGitObjectType(value)
+			if err != nil {
+				return nil, err
+			}
+			tag.objType = objType
+		case "tag":
+			tag.name = value
+		case "tagger":
+			user, time, err := parseIdentity(value)
+			if err != nil {
+				return nil, err
+			}
+			tag.user, tag.time = user, time
+		}
+	}
+	if len(tag.object) == 0 {
+		return nil, errors.New("Invalid tag object, there is no object header")
+	}
+	return tag, nil
+}
+
+//parse "Name Surname <email> unixSeconds zone"
+func parseIdentity(identity string) (*User, *Time, error) {
+	emailStart := strings.Index(identity, " <")
+	emailEnd := strings.Index(identity, "> ")
+	if emailStart == -1 || emailEnd < emailStart {
+		return nil, nil, errors.New(
+			fmt.Sprintf("Invalid identity '%s'", identity),
+		)
+	}
+	parts := strings.Fields(identity[emailEnd+2:])
+	if len(parts) != 2 {
+		return nil, nil, errors.New(
+			fmt.Sprintf("Invalid identity '%s'", identity),
+		)
+	}
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	user := &User{
+		Name:  identity[:emailStart],
+		Email: identity[emailStart+2 : emailEnd],
+	}
+	return user, &Time{unixSeconds: seconds, zone: parts[1]}, nil
+}
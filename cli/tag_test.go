@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/fakes"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+func TestTag(t *testing.T) {
+	user, err := DefaultGitUserAsJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := fakes.TempDir(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gitDir := dir + "/.dzhigit"
+	store := repository.NewFileStore(repository.ObjPath(gitDir))
+	err = os.WriteFile(dir+"/file", []byte("first"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFiles(t, gitDir, "First", dir+"/file")
+	err = os.WriteFile(dir+"/file", []byte("second"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := commitFiles(t, gitDir, "Second", dir+"/file")
+	tagger := &User{Name: "Almas Abdrazak", Email: "almas337519@gmail.com"}
+	createTag := func(name string, target string, message string, force bool) repository.Hash {
+		hash, err := CreateTag(
+			gitDir,
+			name,
+			target,
+			message,
+			force,
+			tagger,
+			CurrentTime(),
+			repository.Reader,
+			store,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	light := createTag("light", "HEAD~1", "", false)
+	if light != first {
+		t.Fatalf("Lightweight tag should point to the commit %s, got %s", first, light)
+	}
+	annotated := createTag("release/v1.0", "", "First release\n\nWith notes", false)
+	deser, err := store.Get(annotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deser.ObjType != repository.TAG {
+		t.Fatalf("Expected a tag object, got %s", deser.ObjType)
+	}
+	tag, err := parseTag(deser.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.object != second || tag.objType != repository.COMMIT || tag.name != "release/v1.0" ||
+		*tag.user != *tagger || tag.message != "First release\n\nWith notes\n" {
+		t.Fatalf("Wrong tag object %+v", tag)
+	}
+	_, err = CreateTag(gitDir, "light", "", "", false, tagger, CurrentTime(), repository.Reader, store)
+	if err == nil {
+		t.Fatal("Existing tag was replaced without force")
+	}
+	if createTag("light", "", "", true) != second {
+		t.Fatal("Tag wasn't replaced with force")
+	}
+	tags, err := ListTags(gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tags, ",") != "light,release/v1.0" {
+		t.Fatalf("Wrong list of tags %v", tags)
+	}
+	resolve := func(revision string) repository.Hash {
+		hash, err := ResolveRevision(gitDir, revision, repository.Reader, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	if resolve("release/v1.0") != annotated || resolve("release/v1.0^{tag}") != annotated {
+		t.Fatal("Annotated tag should resolve to the tag object")
+	}
+	if resolve("release/v1.0^{}") != second || resolve("release/v1.0^{commit}") != second {
+		t.Fatal("Annotated tag should be peeled to the commit")
+	}
+	if resolve("release/v1.0~1") != first {
+		t.Fatal("Ancestry operators should peel tags")
+	}
+	commit, err := ResolveCommit(gitDir, "release/v1.0", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit != second {
+		t.Fatalf("Expected commit %s, got %s", second, commit)
+	}
+	var buffer bytes.Buffer
+	err = ShowTag(&buffer, gitDir, "release/v1.0", repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shown := buffer.String()
+	for _, part := range []string{
+		"tag release/v1.0\nTagger: Almas Abdrazak almas337519@gmail.com\n",
+		"First release\n\nWith notes\n",
+		"commit " + string(second),
+	} {
+		if !strings.Contains(shown, part) {
+			t.Fatalf("Tag output should contain '%s'\n%s", part, shown)
+		}
+	}
+	buffer.Reset()
+	err = LogPatch(&buffer, gitDir, 3, true, repository.Reader, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buffer.String(), "commit "+string(second)+" (HEAD -> master, tag: light, tag: release/v1.0)\n") {
+		t.Fatalf("Log should decorate commits with tags\n%s", buffer.String())
+	}
+	hash, err := DeleteTag(gitDir, "release/v1.0", repository.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != annotated {
+		t.Fatalf("Expected deleted tag to point to %s, got %s", annotated, hash)
+	}
+	if repository.Exists(repository.TagsPath(gitDir) + "release") {
+		t.Fatal("Empty directory of a deleted tag was kept")
+	}
+	//names that point outside of tags
+	_, err = DeleteTag(gitDir, "../heads/master", repository.Reader)
+	if err == nil || !repository.Exists(repository.HeadsPath(gitDir)+"master") {
+		t.Fatal("A branch was deleted as a tag")
+	}
+	err = ShowTag(&buffer, gitDir, "../heads/master", repository.Reader, store)
+	if err == nil {
+		t.Fatal("A branch was shown as a tag")
+	}
+}
@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//parse the content of a tree object into entries
+func parseTree(content string) ([]treeEntry, error) {
+	decoded, err := repository.DecodeTree(content)
+	if err != nil {
+		return nil, err
+	}
+	var entries []treeEntry
+	for _, entry := range decoded {
+		entries = append(
+			entries,
+			treeEntry{
+				mode:    entry.Mode,
+				objType: entry.ObjType(),
+				path:    entry.Name,
+				hash:    entry.Hash,
+			},
+		)
+	}
+	return entries, nil
+}
+
+//Format a tree like "git cat-file -p" does, one "<mode> <type> <hash>\t<name>" line per entry
+func PrettyTree(content string) (string, error) {
+	entries, err := repository.DecodeTree(content)
+	if err != nil {
+		return "", err
+	}
+	builder := strings.Builder{}
+	for _, entry := range entries {
+		builder.WriteString(
+			fmt.Sprintf("%06s %s %s\t%s\n", entry.Mode, entry.ObjType(), entry.Hash, entry.Name),
+		)
+	}
+	return builder.String(), nil
+}
+
+//read a tree object by given hash
+func readTree(
+	treeHash repository.Hash,
+	store repository.ObjectStore,
+) ([]treeEntry, error) {
+	deser, err := store.Get(treeHash)
+	if err != nil {
+		return nil, err
+	}
+	return parseTree(deser.Content)
+}
+
+//collect all blobs of a tree and its subtrees
+//the key is a blob's path relative to the root tree
+//an empty tree hash produces an empty map
+func flattenTree(
+	treeHash repository.Hash,
+	store repository.ObjectStore,
+) (map[string]treeEntry, error) {
+	blobs := make(map[string]treeEntry)
+	if len(treeHash) == 0 {
+		return blobs, nil
+	}
+	err := flattenInto(blobs, treeHash, "", store)
+	if err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+func flattenInto(
+	blobs map[string]treeEntry,
+	treeHash repository.Hash,
+	prefix string,
+	store repository.ObjectStore,
+) error {
+	entries, err := readTree(treeHash, store)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := prefix + entry.path
+		if entry.objType == repository.TREE {
+			err := flattenInto(
+				blobs,
+				entry.hash,
+				path+"/",
+				store,
+			)
+			if err != nil {
+				return err
+			}
+		} else {
+			entry.path = path
+			blobs[path] = entry
+		}
+	}
+	return nil
+}
+
+//a blob that differs between two trees
+//old is nil for added blobs and new is nil for removed ones
+type treeChange struct {
+	path string
+	old  *treeEntry
+	new  *treeEntry
+}
+
+//short status of a change, A - added, D - removed, M - modified
+func (c treeChange) status() string {
+	switch {
+	case c.old == nil:
+		return "A"
+	case c.new == nil:
+		return "D"
+	default:
+		return "M"
+	}
+}
+
+//Compare two trees recursively, subtrees with the same hash are skipped
+//an empty hash stands for an empty tree
+func diffTrees(
+	oldTree repository.Hash,
+	newTree repository.Hash,
+	prefix string,
+	store repository.ObjectStore,
+) ([]treeChange, error) {
+	if oldTree == newTree {
+		return nil, nil
+	}
+	oldEntries, err := treeEntriesByName(oldTree, store)
+	if err != nil {
+		return nil, err
+	}
+	newEntries, err := treeEntriesByName(newTree, store)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for name := range oldEntries {
+		names[name] = true
+	}
+	for name := range newEntries {
+		names[name] = true
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	var changes []treeChange
+	for _, name := range sorted {
+		path := prefix + name
+		var oldBlob, newBlob *treeEntry
+		var oldSubtree, newSubtree repository.Hash
+		if entry, ok := oldEntries[name]; ok {
+			if entry.objType == repository.TREE {
+				oldSubtree = entry.hash
+			} else {
+				entry.path = path
+				oldBlob = &entry
+			}
+		}
+		if entry, ok := newEntries[name]; ok {
+			if entry.objType == repository.TREE {
+				newSubtree = entry.hash
+			} else {
+				entry.path = path
+				newBlob = &entry
+			}
+		}
+		if len(oldSubtree) != 0 || len(newSubtree) != 0 {
+			nested, err := diffTrees(
+				oldSubtree,
+				newSubtree,
+				path+"/",
+				store,
+			)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, nested...)
+		}
+		if oldBlob == nil && newBlob == nil {
+			continue
+		}
+		if oldBlob != nil && newBlob != nil &&
+			oldBlob.hash == newBlob.hash && oldBlob.mode == newBlob.mode {
+			continue
+		}
+		changes = append(changes, treeChange{path: path, old: oldBlob, new: newBlob})
+	}
+	return changes, nil
+}
+
+func treeEntriesByName(
+	treeHash repository.Hash,
+	store repository.ObjectStore,
+) (map[string]treeEntry, error) {
+	entries := make(map[string]treeEntry)
+	if len(treeHash) == 0 {
+		return entries, nil
+	}
+	parsed, err := readTree(treeHash, store)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range parsed {
+		entries[entry.path] = entry
+	}
+	return entries, nil
+}
+
+//Compare two flattened trees
+func diffFlatTrees(
+	oldBlobs map[string]treeEntry,
+	newBlobs map[string]treeEntry,
+) []treeChange {
+	var changes []treeChange
+	for path, oldBlob := range oldBlobs {
+		old := oldBlob
+		newBlob, ok := newBlobs[path]
+		if !ok {
+			changes = append(changes, treeChange{path: path, old: &old})
+		} else if newBlob.hash != old.hash || newBlob.mode != old.mode {
+			new := newBlob
+			changes = append(changes, treeChange{path: path, old: &old, new: &new})
+		}
+	}
+	for path, newBlob := range newBlobs {
+		if _, ok := oldBlobs[path]; !ok {
+			new := newBlob
+			changes = append(changes, treeChange{path: path, new: &new})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].path < changes[j].path
+	})
+	return changes
+}
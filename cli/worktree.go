@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//permissions of a working tree file with given mode
+func filePermission(mode repository.Mode) os.FileMode {
+	if mode == repository.EXECUTABLE {
+		return 0755
+	}
+	return 0644
+}
+
+//check if a file exists in the working tree, a dangling symbolic link exists too
+func workTreeExists(file string) bool {
+	_, err := os.Lstat(file)
+	return err == nil
+}
+
+//read a file from the working tree
+//the content of a symbolic link is the path it points to
+func readWorkTreeFile(file string) ([]byte, os.FileInfo, error) {
+	info, err := os.Lstat(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []byte(target), info, nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, info, nil
+}
+
+//write content into the working tree creating missing parent directories
+//content of a symbolic link is the path it points to
+func writeWorkTreeFile(
+	workTree string,
+	path string,
+	content []byte,
+	mode repository.Mode,
+) error {
+	file := filepath.Join(workTree, path)
+	err := os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	//a file can't be replaced by a symbolic link and vice versa
+	info, err := os.Lstat(file)
+	if err == nil && (mode == repository.SYMLINK || info.Mode()&os.ModeSymlink != 0) {
+		err = os.Remove(file)
+		if err != nil {
+			return err
+		}
+	}
+	if mode == repository.SYMLINK {
+		return os.Symlink(string(content), file)
+	}
+	err = os.WriteFile(file, content, filePermission(mode))
+	if err != nil {
+		return err
+	}
+	//WriteFile doesn't change permissions of an existing file
+	return os.Chmod(file, filePermission(mode))
+}
+
+//write the content of a blob into the working tree
+func writeWorkTreeBlob(
+	workTree string,
+	entry treeEntry,
+	store repository.ObjectStore,
+) error {
+	content, err := loadBlob(entry.hash, store)
+	if err != nil {
+		return err
+	}
+	return writeWorkTreeFile(workTree, entry.path, []byte(content), entry.mode)
+}
+
+//remove a file from the working tree
+//and all its parent directories that became empty
+func removeWorkTreeFile(workTree string, path string) error {
+	file := filepath.Join(workTree, path)
+	err := os.Remove(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	root := filepath.Clean(workTree)
+	for dir := filepath.Dir(file); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) != 0 {
+			break
+		}
+		err = os.Remove(dir)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//make the working tree reflect given changes between two trees
+//files are removed before new ones are written because
+//a file can be replaced by a directory with the same name and vice versa
+func applyTreeChanges(
+	workTree string,
+	changes []treeChange,
+	store repository.ObjectStore,
+) error {
+	for _, change := range changes {
+		if change.new != nil {
+			continue
+		}
+		err := removeWorkTreeFile(workTree, change.path)
+		if err != nil {
+			return err
+		}
+	}
+	for _, change := range changes {
+		if change.new == nil {
+			continue
+		}
+		err := writeWorkTreeBlob(workTree, *change.new, store)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//replace the index with given blobs
+//stat data is taken from files in the working tree
+func writeIndexFromTree(
+	gitRepoPath string,
+	workTree string,
+	blobs map[string]treeEntry,
+) error {
+	indexPath := repository.IndexPath(gitRepoPath)
+	//the index is read first, so a concurrent change of the file is detected
+	idx, err := index.Read(indexPath)
+	if err != nil {
+		return err
+	}
+	idx.Clear()
+	for path, blob := range blobs {
+		var stat index.Stat
+		file := filepath.Join(workTree, path)
+		if workTreeExists(file) {
+			stat, err = index.StatFile(file)
+			if err != nil {
+				return err
+			}
+		}
+		idx.Add(index.NewEntry(path, blob.mode, blob.hash, stat))
+	}
+	return idx.Write(indexPath)
+}
@@ -0,0 +1,120 @@
+package diff
+
+import "strings"
+
+const (
+	ConflictStart     = "<<<<<<<"
+	ConflictSeparator = "======="
+	ConflictEnd       = ">>>>>>>"
+)
+
+//Three-way merge of two contents that were derived from the same base
+//Returns merged content and true if there were conflicting changes,
+//conflicting parts are surrounded by conflict markers with given labels
+//Binary contents can't be merged, ours is returned as a conflict
+func Merge(
+	base string,
+	ours string,
+	theirs string,
+	oursLabel string,
+	theirsLabel string,
+) (string, bool) {
+	if IsBinary(base) || IsBinary(ours) || IsBinary(theirs) {
+		return ours, true
+	}
+	baseLines, oursLines, theirsLines := Lines(base), Lines(ours), Lines(theirs)
+	matchOurs := matches(Myers(baseLines, oursLines))
+	matchTheirs := matches(Myers(baseLines, theirsLines))
+	builder := strings.Builder{}
+	conflict := false
+	resolve := func(baseChunk, oursChunk, theirsChunk []string) {
+		switch {
+		case equalLines(oursChunk, theirsChunk) || equalLines(theirsChunk, baseChunk):
+			writeLines(&builder, oursChunk)
+		case equalLines(oursChunk, baseChunk):
+			writeLines(&builder, theirsChunk)
+		default:
+			conflict = true
+			builder.WriteString(ConflictStart + " " + oursLabel + "\n")
+			writeConflictLines(&builder, oursChunk)
+			builder.WriteString(ConflictSeparator + "\n")
+			writeConflictLines(&builder, theirsChunk)
+			builder.WriteString(ConflictEnd + " " + theirsLabel + "\n")
+		}
+	}
+	o, a, b := 0, 0, 0
+	for {
+		//length of a run of base lines that are kept on both sides
+		stable := 0
+		for o+stable < len(baseLines) && a+stable < len(oursLines) && b+stable < len(theirsLines) {
+			oursLine, okOurs := matchOurs[o+stable]
+			theirsLine, okTheirs := matchTheirs[o+stable]
+			if !okOurs || !okTheirs || oursLine != a+stable || theirsLine != b+stable {
+				break
+			}
+			stable++
+		}
+		if stable != 0 {
+			writeLines(&builder, baseLines[o:o+stable])
+			o, a, b = o+stable, a+stable, b+stable
+			continue
+		}
+		//find the next base line that is kept on both sides
+		found := false
+		for next := o; next < len(baseLines); next++ {
+			oursLine, okOurs := matchOurs[next]
+			theirsLine, okTheirs := matchTheirs[next]
+			if okOurs && okTheirs {
+				resolve(baseLines[o:next], oursLines[a:oursLine], theirsLines[b:theirsLine])
+				o, a, b = next, oursLine, theirsLine
+				found = true
+				break
+			}
+		}
+		if !found {
+			if o < len(baseLines) || a < len(oursLines) || b < len(theirsLines) {
+				resolve(baseLines[o:], oursLines[a:], theirsLines[b:])
+			}
+			break
+		}
+	}
+	return builder.String(), conflict
+}
+
+//map from a line in old content to the same line in new content
+func matches(edits []Edit) map[int]int {
+	result := make(map[int]int)
+	for _, edit := range edits {
+		if edit.Op == EQUAL {
+			result[edit.OldLine] = edit.NewLine
+		}
+	}
+	return result
+}
+
+func equalLines(first []string, second []string) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(builder *strings.Builder, lines []string) {
+	for _, line := range lines {
+		builder.WriteString(line)
+	}
+}
+
+//write lines and make sure that the last one ends with a new line,
+//otherwise a conflict marker would be glued to it
+func writeConflictLines(builder *strings.Builder, lines []string) {
+	writeLines(builder, lines)
+	if len(lines) != 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		builder.WriteString("\n")
+	}
+}
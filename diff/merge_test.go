@@ -0,0 +1,37 @@
+package diff
+
+import "testing"
+
+func TestMerge_clean(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	ours := "A\nb\nc\nd\ne\n"
+	theirs := "a\nb\nc\nd\nE\nf\n"
+	merged, conflict := Merge(base, ours, theirs, "ours", "theirs")
+	if conflict {
+		t.Fatal("Changes in different lines should not conflict")
+	}
+	if merged != "A\nb\nc\nd\nE\nf\n" {
+		t.Fatalf("Wrong merged content '%s'", merged)
+	}
+}
+
+func TestMerge_sameChange(t *testing.T) {
+	merged, conflict := Merge("a\nb\n", "a\nB\n", "a\nB\n", "ours", "theirs")
+	if conflict || merged != "a\nB\n" {
+		t.Fatalf("The same change on both sides should be taken once, got '%s'", merged)
+	}
+}
+
+func TestMerge_conflict(t *testing.T) {
+	base := "a\nb\nc\n"
+	ours := "a\nours\nc\n"
+	theirs := "a\ntheirs"
+	merged, conflict := Merge(base, ours, theirs, "HEAD", "feature")
+	if !conflict {
+		t.Fatal("Different changes of the same line should conflict")
+	}
+	expected := "a\n<<<<<<< HEAD\nours\nc\n=======\ntheirs\n>>>>>>> feature\n"
+	if merged != expected {
+		t.Fatalf("Wrong conflict, expected\n%s\ngot\n%s", expected, merged)
+	}
+}
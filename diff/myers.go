@@ -0,0 +1,106 @@
+package diff
+
+import "strings"
+
+type Operation int
+
+const (
+	EQUAL Operation = iota
+	INSERT
+	DELETE
+)
+
+//single line of an edit script
+//oldLine and newLine are zero based positions of this line in old and new content,
+//for insertions oldLine is the position of the next old line and vice versa
+type Edit struct {
+	Op      Operation
+	OldLine int
+	NewLine int
+	Text    string
+}
+
+//Split content into lines, every line keeps its trailing line break
+//the last line doesn't have it if content doesn't end with a new line
+func Lines(content string) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+//Shortest edit script that transforms old lines into new ones
+//Implementation of Myers' O(ND) greedy algorithm
+func Myers(old []string, new []string) []Edit {
+	n, m := len(old), len(new)
+	max := n + m
+	offset := max + 1
+	//furthest reaching x for every diagonal k
+	v := make([]int, 2*max+3)
+	//step d only reads diagonals from -d-1 to d+1, so only they are kept for the backtracking
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && old[x] == new[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, old, new)
+			}
+		}
+	}
+	return nil
+}
+
+//walk the trace from the end to the beginning to restore edits
+//a snapshot of step d starts at diagonal -d-1
+func backtrack(trace [][]int, old []string, new []string) []Edit {
+	x, y := len(old), len(new)
+	var edits []Edit
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		offset := d + 1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Op: EQUAL, OldLine: x - 1, NewLine: y - 1, Text: old[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, Edit{Op: INSERT, OldLine: x, NewLine: y - 1, Text: new[y-1]})
+			} else {
+				edits = append(edits, Edit{Op: DELETE, OldLine: x - 1, NewLine: y, Text: old[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
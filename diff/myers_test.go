@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMyers(t *testing.T) {
+	old := strings.Split("ABCABBA", "")
+	new := strings.Split("CBABAC", "")
+	edits := Myers(old, new)
+	changes := 0
+	var restored []string
+	for _, edit := range edits {
+		if edit.Op != EQUAL {
+			changes++
+		}
+		if edit.Op != DELETE {
+			restored = append(restored, edit.Text)
+		}
+	}
+	//the shortest edit script for this example has 5 edits
+	if changes != 5 {
+		t.Fatalf("Wrong amount of edits, 5 expected, got %d", changes)
+	}
+	if strings.Join(restored, "") != "CBABAC" {
+		t.Fatalf("Edits don't produce new content, got '%s'", strings.Join(restored, ""))
+	}
+}
+
+func TestMyers_empty(t *testing.T) {
+	edits := Myers(nil, []string{"a\n", "b\n"})
+	if len(edits) != 2 || edits[0].Op != INSERT || edits[1].Op != INSERT {
+		t.Fatalf("Only insertions expected, got %v", edits)
+	}
+	if len(Myers(nil, nil)) != 0 {
+		t.Fatal("Two empty contents should not have edits")
+	}
+}
+
+//edit scripts of random contents are compared with the length of the longest common subsequence
+func TestMyers_random(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	content := func() []string {
+		lines := make([]string, random.Intn(40))
+		for i := range lines {
+			lines[i] = string(rune('a' + random.Intn(4)))
+		}
+		return lines
+	}
+	for run := 0; run < 200; run++ {
+		old, new := content(), content()
+		//lcs[i][j] is the longest common subsequence of old[i:] and new[j:]
+		lcs := make([][]int, len(old)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(new)+1)
+		}
+		for i := len(old) - 1; i >= 0; i-- {
+			for j := len(new) - 1; j >= 0; j-- {
+				switch {
+				case old[i] == new[j]:
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				case lcs[i+1][j] > lcs[i][j+1]:
+					lcs[i][j] = lcs[i+1][j]
+				default:
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		changes := 0
+		var restored []string
+		for _, edit := range Myers(old, new) {
+			if edit.Op != EQUAL {
+				changes++
+			}
+			if edit.Op != DELETE {
+				restored = append(restored, edit.Text)
+			}
+		}
+		if strings.Join(restored, "") != strings.Join(new, "") {
+			t.Fatalf("Edits of %v don't produce %v, got %v", old, new, restored)
+		}
+		if expected := len(old) + len(new) - 2*lcs[0][0]; changes != expected {
+			t.Fatalf("Edit script of %v and %v is not the shortest, %d expected, got %d", old, new, expected, changes)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	lines := Lines("first\nsecond")
+	if len(lines) != 2 || lines[0] != "first\n" || lines[1] != "second" {
+		t.Fatalf("Wrong lines %v", lines)
+	}
+	if len(Lines("")) != 0 {
+		t.Fatal("Empty content should not have lines")
+	}
+}
@@ -0,0 +1,153 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+//git looks for a null byte in the first 8000 bytes
+const binaryCheckSize = 8000
+
+//a group of changes surrounded by context lines
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Edits    []Edit
+}
+
+//Check if content looks like a binary file
+func IsBinary(content string) bool {
+	if len(content) > binaryCheckSize {
+		content = content[:binaryCheckSize]
+	}
+	return strings.IndexByte(content, 0) != -1
+}
+
+//Group edits into hunks with given amount of context lines
+//hunks which context overlaps are merged into one
+func Hunks(edits []Edit, context int) []Hunk {
+	var hunks []Hunk
+	i := 0
+	for i < len(edits) {
+		if edits[i].Op == EQUAL {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		last := i
+		j := i
+		for j < len(edits) {
+			if edits[j].Op != EQUAL {
+				last = j
+				j++
+				continue
+			}
+			run := j
+			for run < len(edits) && edits[run].Op == EQUAL {
+				run++
+			}
+			if run == len(edits) || run-j > 2*context {
+				break
+			}
+			j = run
+		}
+		stop := last + 1 + context
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+		hunks = append(hunks, newHunk(edits[start:stop]))
+		i = stop
+	}
+	return hunks
+}
+
+func newHunk(edits []Edit) Hunk {
+	hunk := Hunk{
+		OldStart: edits[0].OldLine + 1,
+		NewStart: edits[0].NewLine + 1,
+		Edits:    edits,
+	}
+	for _, edit := range edits {
+		if edit.Op != INSERT {
+			hunk.OldLines++
+		}
+		if edit.Op != DELETE {
+			hunk.NewLines++
+		}
+	}
+	//an empty range points to the line before it
+	if hunk.OldLines == 0 {
+		hunk.OldStart--
+	}
+	if hunk.NewLines == 0 {
+		hunk.NewStart--
+	}
+	return hunk
+}
+
+//Header of a hunk
+//Example: "@@ -1,3 +1,4 @@"
+func (h Hunk) Header() string {
+	return fmt.Sprintf(
+		"@@ -%s +%s @@",
+		hunkRange(h.OldStart, h.OldLines),
+		hunkRange(h.NewStart, h.NewLines),
+	)
+}
+
+func (h Hunk) String() string {
+	builder := strings.Builder{}
+	builder.WriteString(h.Header() + "\n")
+	for _, edit := range h.Edits {
+		switch edit.Op {
+		case EQUAL:
+			builder.WriteString(" ")
+		case INSERT:
+			builder.WriteString("+")
+		case DELETE:
+			builder.WriteString("-")
+		}
+		builder.WriteString(edit.Text)
+		if !strings.HasSuffix(edit.Text, "\n") {
+			builder.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return builder.String()
+}
+
+func hunkRange(start int, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+//Unified diff of two contents
+//oldName and newName are printed in the header, use /dev/null for a missing file
+//returns an empty string if contents are the same
+func Unified(
+	oldName string,
+	newName string,
+	oldContent string,
+	newContent string,
+	context int,
+) string {
+	if oldContent == newContent {
+		return ""
+	}
+	if IsBinary(oldContent) || IsBinary(newContent) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+	}
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+	edits := Myers(Lines(oldContent), Lines(newContent))
+	for _, hunk := range Hunks(edits, context) {
+		builder.WriteString(hunk.String())
+	}
+	return builder.String()
+}
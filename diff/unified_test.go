@@ -0,0 +1,74 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	new := "1\n2\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n12\nthirteen\n"
+	expected := `--- a/file
++++ b/file
+@@ -1,7 +1,7 @@
+ 1
+ 2
+ 3
+-4
++four
+ 5
+ 6
+ 7
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++thirteen
+`
+	result := Unified("a/file", "b/file", old, new, 3)
+	if result != expected {
+		t.Fatalf("Wrong unified diff, expected\n%s\ngot\n%s", expected, result)
+	}
+}
+
+func TestUnified_mergedHunksAndContext(t *testing.T) {
+	old := "a\nb\nc\nd\n"
+	new := "A\nb\nc\nD\n"
+	expected := `--- a/file
++++ b/file
+@@ -1,4 +1,4 @@
+-a
++A
+ b
+ c
+-d
++D
+`
+	result := Unified("a/file", "b/file", old, new, 1)
+	if result != expected {
+		t.Fatalf("Wrong unified diff, expected\n%s\ngot\n%s", expected, result)
+	}
+}
+
+func TestUnified_newFileWithoutNewLine(t *testing.T) {
+	expected := `--- /dev/null
++++ b/file
+@@ -0,0 +1 @@
++content
+\ No newline at end of file
+`
+	result := Unified("/dev/null", "b/file", "", "content", 3)
+	if result != expected {
+		t.Fatalf("Wrong unified diff, expected\n%s\ngot\n%s", expected, result)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if !IsBinary("PNG\x00\x01") {
+		t.Fatal("Content with null byte is binary")
+	}
+	if IsBinary("plain text") {
+		t.Fatal("Plain text is not binary")
+	}
+	result := Unified("a/img", "b/img", "\x00old", "\x00new", 3)
+	if result != "Binary files a/img and b/img differ\n" {
+		t.Fatalf("Wrong diff for binary files '%s'", result)
+	}
+}
@@ -5,29 +5,30 @@ import (
 	"io/ioutil"
 	"os"
 
+	"github.com/strogiyotec/dzhigit/index"
 	"github.com/strogiyotec/dzhigit/repository"
 )
 
-func FakeIndexEntries(entries []repository.SerializedGitObject, files []os.File, objPath string) ([]repository.IndexEntry, error) {
+func FakeIndexEntries(entries []repository.SerializedGitObject, files []os.File, store repository.ObjectStore) ([]index.Entry, error) {
 	if len(entries) != len(files) {
 		return nil, errors.New("the size of entries and files has to be the same")
 	}
-	var indexEntries []repository.IndexEntry
+	var indexEntries []index.Entry
 	for i := 0; i < len(entries); i++ {
 		file := files[i]
 		entry := entries[i]
-		index, err := repository.NewIndex(file.Name(), repository.FILE, entry.Hash, objPath)
+		indexEntry, err := index.NewFileEntry(file.Name(), repository.FILE, entry.Hash, store)
 		if err != nil {
 			return nil, err
 		}
-		indexEntries = append(indexEntries, *index)
+		indexEntries = append(indexEntries, *indexEntry)
 	}
 	return indexEntries, nil
 }
 
+//Create a repository in a new temporary directory, so tests don't share a working tree
 func TempDir(user []byte) (string, error) {
-	dir := "/tmp/dzhigit"
-	err := os.MkdirAll(dir, 0777)
+	dir, err := ioutil.TempDir("", "dzhigit")
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,239 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+//name of a file with ignore patterns, it can be placed in any directory
+//of a working tree and its patterns are relative to that directory
+const FileName = ".dzhigitignore"
+
+//single line of an ignore file
+type Pattern struct {
+	Source   string //file the pattern was read from
+	Line     int    //line number in the source, starts from 1
+	Text     string //the pattern as it's written in the source
+	Negated  bool   //"!" pattern re-includes a path excluded by a previous pattern
+	dirOnly  bool   //pattern with a trailing "/" matches only directories
+	base     string //directory relative to the working tree the pattern applies to
+	compiled *regexp.Regexp
+}
+
+//Check if a path relative to the working tree matches the pattern
+func (p *Pattern) Matches(relative string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if len(p.base) != 0 {
+		if !strings.HasPrefix(relative, p.base+"/") {
+			return false
+		}
+		relative = relative[len(p.base)+1:]
+	}
+	return p.compiled.MatchString(relative)
+}
+
+//Parse patterns of an ignore file that is placed in given directory
+//relative to the working tree, an empty base means the root of the working tree
+//Supported syntax:
+//  # comment      - lines starting with "#" and blank lines are skipped
+//  *.log          - a pattern without a slash matches a name at any level
+//  /build, a/b    - a pattern with a leading or middle slash is anchored to the base
+//  logs/          - a trailing slash matches only directories
+//  **/tmp, a/**   - "**" matches any number of directories
+//  !keep.log      - negation re-includes a previously excluded path
+//  \#, \!         - a backslash escapes the special meaning of a character
+func Parse(content string, source string, base string) []Pattern {
+	var patterns []Pattern
+	for i, line := range strings.Split(content, "\n") {
+		line = trimTrailingSpaces(strings.TrimSuffix(line, "\r"))
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern := Pattern{Source: source, Line: i + 1, Text: line, base: base}
+		glob := line
+		if strings.HasPrefix(glob, "!") {
+			pattern.Negated = true
+			glob = glob[1:]
+		} else if strings.HasPrefix(glob, `\!`) || strings.HasPrefix(glob, `\#`) {
+			glob = glob[1:]
+		}
+		if strings.HasSuffix(glob, "/") {
+			pattern.dirOnly = true
+			glob = strings.TrimRight(glob, "/")
+		}
+		if len(glob) == 0 {
+			continue
+		}
+		anchored := strings.Contains(glob, "/")
+		glob = strings.TrimPrefix(glob, "/")
+		expr := globToRegexp(glob)
+		if !anchored {
+			expr = "(?:.*/)?" + expr
+		}
+		compiled, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			//a malformed pattern never matches like in git
+			continue
+		}
+		pattern.compiled = compiled
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+//Matcher of paths of a working tree against ignore files
+//Patterns are read from the exclude file of the repository
+//and from ignore files of every directory on the way to a path,
+//a pattern in a deeper directory takes precedence over a higher one
+//and the last matching pattern of a file wins
+type Matcher struct {
+	workTree string
+	exclude  []Pattern
+	dirs     map[string][]Pattern //patterns of ignore files by directory
+}
+
+//Create a matcher for given working tree,
+//the exclude file is optional and may not exist
+func NewMatcher(workTree string, excludeFile string) (*Matcher, error) {
+	matcher := &Matcher{workTree: workTree, dirs: make(map[string][]Pattern)}
+	patterns, err := readPatterns(excludeFile, "")
+	if err != nil {
+		return nil, err
+	}
+	matcher.exclude = patterns
+	return matcher, nil
+}
+
+//Find the pattern that decides if a path relative to the working tree is ignored
+//Returns nil if no pattern matches, a negated pattern means the path is not ignored
+//A path inside of an ignored directory is ignored by the pattern of the directory
+func (m *Matcher) Match(relative string, isDir bool) (*Pattern, error) {
+	relative = filepath.ToSlash(filepath.Clean(relative))
+	if relative == "." {
+		return nil, nil
+	}
+	parts := strings.Split(relative, "/")
+	for i := 1; i < len(parts); i++ {
+		pattern, err := m.matchSingle(strings.Join(parts[:i], "/"), true)
+		if err != nil {
+			return nil, err
+		}
+		if pattern != nil && !pattern.Negated {
+			return pattern, nil
+		}
+	}
+	return m.matchSingle(relative, isDir)
+}
+
+//Check if a path relative to the working tree is ignored
+func (m *Matcher) Ignored(relative string, isDir bool) (bool, error) {
+	pattern, err := m.Match(relative, isDir)
+	if err != nil {
+		return false, err
+	}
+	return pattern != nil && !pattern.Negated, nil
+}
+
+//the last matching pattern without looking at parent directories
+func (m *Matcher) matchSingle(relative string, isDir bool) (*Pattern, error) {
+	candidates := [][]Pattern{m.exclude}
+	parts := strings.Split(relative, "/")
+	for i := 0; i < len(parts); i++ {
+		patterns, err := m.dirPatterns(strings.Join(parts[:i], "/"))
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, patterns)
+	}
+	for i := len(candidates) - 1; i >= 0; i-- {
+		patterns := candidates[i]
+		for j := len(patterns) - 1; j >= 0; j-- {
+			if patterns[j].Matches(relative, isDir) {
+				return &patterns[j], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+//patterns of the ignore file in given directory, read once
+func (m *Matcher) dirPatterns(dir string) ([]Pattern, error) {
+	if patterns, ok := m.dirs[dir]; ok {
+		return patterns, nil
+	}
+	patterns, err := readPatterns(filepath.Join(m.workTree, filepath.FromSlash(dir), FileName), dir)
+	if err != nil {
+		return nil, err
+	}
+	m.dirs[dir] = patterns
+	return patterns, nil
+}
+
+//patterns of a file, a missing file has no patterns
+func readPatterns(file string, base string) ([]Pattern, error) {
+	content, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(content), file, base), nil
+}
+
+//trailing spaces are ignored unless they are escaped with a backslash
+func trimTrailingSpaces(line string) string {
+	trimmed := strings.TrimRight(line, " ")
+	if strings.HasSuffix(trimmed, `\`) && len(trimmed) < len(line) {
+		return trimmed + " "
+	}
+	return trimmed
+}
+
+//translate a glob to a regular expression
+//"*" and "?" don't match a slash, "**" matches across directories
+func globToRegexp(glob string) string {
+	builder := strings.Builder{}
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			builder.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && (i == 0 || glob[i-1] == '/'):
+			builder.WriteString(".*")
+			i++
+		case c == '*':
+			builder.WriteString("[^/]*")
+		case c == '?':
+			builder.WriteString("[^/]")
+		case c == '[':
+			end := strings.Index(glob[i+1:], "]")
+			if end == -1 {
+				builder.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if len(class) == 0 {
+				builder.WriteString(regexp.QuoteMeta("[]"))
+				i++
+				continue
+			}
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			builder.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			builder.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return builder.String()
+}
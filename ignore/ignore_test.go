@@ -0,0 +1,135 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse_patterns(t *testing.T) {
+	patterns := Parse(
+		"# comment\n\n*.log\n!keep.log\n/build\nlogs/\ndocs/**/*.md\n**/tmp\nout/**\n\\#hash\nspace\\ \n",
+		".dzhigitignore",
+		"",
+	)
+	cases := []struct {
+		path    string
+		isDir   bool
+		matches bool
+		line    int
+	}{
+		{"debug.log", false, true, 3},
+		{"src/debug.log", false, true, 3},
+		{"keep.log", false, true, 4},
+		{"build", true, true, 5},
+		{"src/build", true, false, 0},
+		{"logs", true, true, 6},
+		{"logs", false, false, 0},
+		{"src/logs", true, true, 6},
+		{"docs/readme.md", false, true, 7},
+		{"docs/a/b/readme.md", false, true, 7},
+		{"src/docs/readme.md", false, false, 0},
+		{"tmp", true, true, 8},
+		{"a/b/tmp", true, true, 8},
+		{"out/a/b", false, true, 9},
+		{"out", true, false, 0},
+		{"#hash", false, true, 10},
+		{"space ", false, true, 11},
+		{"main.go", false, false, 0},
+	}
+	for _, c := range cases {
+		var matched *Pattern
+		for i := len(patterns) - 1; i >= 0; i-- {
+			if patterns[i].Matches(c.path, c.isDir) {
+				matched = &patterns[i]
+				break
+			}
+		}
+		if !c.matches {
+			if matched != nil {
+				t.Fatalf("'%s' should not match, matched line %d", c.path, matched.Line)
+			}
+			continue
+		}
+		if matched == nil || matched.Line != c.line {
+			t.Fatalf("'%s' should match line %d, got %v", c.path, c.line, matched)
+		}
+	}
+	if !patterns[1].Negated {
+		t.Fatal("'!keep.log' should be negated")
+	}
+}
+
+func TestParse_glob(t *testing.T) {
+	patterns := Parse("file?.[ch]\nlib[!0-9].a\n", "exclude", "")
+	if !patterns[0].Matches("file1.c", false) || patterns[0].Matches("file10.c", false) {
+		t.Fatal("'?' should match exactly one character")
+	}
+	if patterns[0].Matches("file1.o", false) {
+		t.Fatal("Character class should match only listed characters")
+	}
+	if !patterns[1].Matches("libx.a", false) || patterns[1].Matches("lib1.a", false) {
+		t.Fatal("'[!...]' should match characters that are not listed")
+	}
+}
+
+func TestMatcher_nested(t *testing.T) {
+	dir, err := os.MkdirTemp("", "ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exclude := filepath.Join(dir, "exclude")
+	files := map[string]string{
+		exclude:                                 "*.swp\n",
+		filepath.Join(dir, FileName):            "*.log\nvendor/\n",
+		filepath.Join(dir, "src", FileName):     "!important.log\n/gen\n",
+		filepath.Join(dir, "src", "a", "x.txt"): "x",
+	}
+	for path, content := range files {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	matcher, err := NewMatcher(dir, exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"a.swp", false, true},
+		{"debug.log", false, true},
+		{"src/debug.log", false, true},
+		{"src/important.log", false, false},
+		{"important.log", false, true},
+		{"src/gen", true, true},
+		{"gen", true, false},
+		{"src/a/gen", true, false},
+		{"vendor/lib/important.log", false, true},
+		{"src/a/x.txt", false, false},
+	}
+	for _, c := range cases {
+		ignored, err := matcher.Ignored(c.path, c.isDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ignored != c.ignored {
+			t.Fatalf("'%s' ignored should be %v", c.path, c.ignored)
+		}
+	}
+	pattern, err := matcher.Match("src/important.log", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pattern == nil || !pattern.Negated || pattern.Source != filepath.Join(dir, "src", FileName) {
+		t.Fatalf("Negated pattern of src should decide, got %v", pattern)
+	}
+}
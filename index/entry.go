@@ -0,0 +1,155 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//stage of an entry, entries of a conflicting path have stages 1 to 3
+type Stage uint8
+
+const (
+	Merged Stage = 0 //the only entry of a path without conflicts
+	Base   Stage = 1 //the version of a common ancestor
+	Ours   Stage = 2 //the version of the current branch
+	Theirs Stage = 3 //the version of a branch being merged
+)
+
+//stat data of a file used to detect changes without rehashing it
+//values are truncated to 32 bits like in git
+type Stat struct {
+	CTime     uint32
+	CTimeNano uint32
+	MTime     uint32
+	MTimeNano uint32
+	Dev       uint32
+	Ino       uint32
+	UID       uint32
+	GID       uint32
+	Size      uint32
+}
+
+//Read stat data of a file, symbolic links are not followed
+func StatFile(path string) (Stat, error) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		return Stat{}, err
+	}
+	return Stat{
+		CTime:     uint32(st.Ctim.Sec),
+		CTimeNano: uint32(st.Ctim.Nsec),
+		MTime:     uint32(st.Mtim.Sec),
+		MTimeNano: uint32(st.Mtim.Nsec),
+		Dev:       uint32(st.Dev),
+		Ino:       uint32(st.Ino),
+		UID:       st.Uid,
+		GID:       st.Gid,
+		Size:      uint32(st.Size),
+	}, nil
+}
+
+//Check if a file still has the same stat data,
+//an empty stat never matches so such an entry is always compared by content
+func (s Stat) Matches(other Stat) bool {
+	return s != Stat{} && s == other
+}
+
+//single file staged in the index
+type Entry struct {
+	path  string //path relative to the working tree
+	mode  repository.Mode
+	hash  repository.Hash
+	stage Stage
+	stat  Stat
+}
+
+//Create an entry for a blob that is already saved
+//an empty stat makes the entry compared with the working tree by content
+func NewEntry(
+	path string,
+	mode repository.Mode,
+	hash repository.Hash,
+	stat Stat,
+) Entry {
+	return Entry{path: path, mode: mode, hash: hash, stat: stat}
+}
+
+//Create an entry for an existing file and a saved blob
+//file - path of the file that is used as a path of the entry
+//store - objects of the repository where the blob is saved
+func NewFileEntry(
+	file string,
+	mode repository.Mode,
+	hash repository.Hash,
+	store repository.ObjectStore,
+) (*Entry, error) {
+	if !repository.Exists(file) {
+		return nil, errors.New(fmt.Sprintf("File %s doesn't exist", file))
+	}
+	if !store.Has(hash) {
+		return nil, errors.New(fmt.Sprintf("Blob with hash %s doesn't exist", hash))
+	}
+	stat, err := StatFile(file)
+	if err != nil {
+		return nil, err
+	}
+	entry := NewEntry(file, mode, hash, stat)
+	return &entry, nil
+}
+
+//The same entry at a different stage
+func (entry Entry) WithStage(stage Stage) Entry {
+	entry.stage = stage
+	return entry
+}
+
+func (entry Entry) Path() string {
+	return entry.path
+}
+
+func (entry Entry) Mode() repository.Mode {
+	return entry.mode
+}
+
+func (entry Entry) Hash() repository.Hash {
+	return entry.hash
+}
+
+func (entry Entry) Stage() Stage {
+	return entry.stage
+}
+
+func (entry Entry) Stat() Stat {
+	return entry.stat
+}
+
+//a leading separator of a path given to update-index is dropped
+//as a tree entry can't have an empty name
+func (entry Entry) PathParts() []string {
+	separator := string(os.PathSeparator)
+	return strings.Split(strings.TrimPrefix(entry.path, separator), separator)
+}
+
+//Get the depth of a file for given index
+func (entry Entry) Depth() int {
+	return len(entry.PathParts())
+}
+
+//Entry of a tree that holds the file at given depth of its path
+func (entry Entry) TreeEntry(part int) repository.TreeEntry {
+	return repository.TreeEntry{
+		Mode: entry.mode,
+		Name: entry.PathParts()[part],
+		Hash: entry.hash,
+	}
+}
+
+//"<mode> <hash> <stage>\t<path>" like "git ls-files --stage" prints it
+func (entry Entry) String() string {
+	return fmt.Sprintf("%s %s %d\t%s", entry.mode, entry.hash, entry.stage, entry.path)
+}
@@ -0,0 +1,363 @@
+package index
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+const (
+	signature = "DIRC"
+	version   = 2
+	//size of the fixed part of an entry: ten stat fields, a hash and flags
+	entryHeaderSize = 10*4 + sha1.Size + 2
+	//the name length in flags saturates at this value
+	maxNameLength = 0xFFF
+)
+
+//Staging area of a repository, entries are kept by path and stage
+//and sorted only when they are listed or written
+type Index struct {
+	entries map[entryKey]Entry
+	//the index was read from a file, Write fails if another process changed the file since then
+	read bool
+	//hash of the content of the file when it was read, nil for a missing or empty file
+	base []byte
+}
+
+//an index has at most one entry of every stage of a path
+type entryKey struct {
+	path  string
+	stage Stage
+}
+
+//Create an empty index
+func New() *Index {
+	return &Index{entries: make(map[entryKey]Entry)}
+}
+
+//Read an index file
+//A missing file is an empty index and an index in the text format
+//of older versions is upgraded, it's written in binary by the next Write
+func Read(indexPath string) (*Index, error) {
+	content, err := readFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	idx := New()
+	if len(content) != 0 {
+		if bytes.HasPrefix(content, []byte(signature)) {
+			idx, err = Decode(content)
+		} else {
+			idx, err = decodeText(string(content))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	idx.read = true
+	idx.base = contentHash(content)
+	return idx, nil
+}
+
+//Save the index in the binary format of git
+//The file is replaced under "index.lock", an index that was read from the file
+//is only saved if the file wasn't changed by another process after that
+func (idx *Index) Write(indexPath string) error {
+	content, err := idx.Encode()
+	if err != nil {
+		return err
+	}
+	lock, err := repository.LockFile(indexPath)
+	if err != nil {
+		return err
+	}
+	if idx.read {
+		current, err := readFile(indexPath)
+		if err != nil {
+			lock.Rollback()
+			return err
+		}
+		if !bytes.Equal(contentHash(current), idx.base) {
+			lock.Rollback()
+			return errors.New(
+				fmt.Sprintf("%s was changed by another process after it was read", indexPath),
+			)
+		}
+	}
+	err = lock.Commit(content, 0644)
+	if err != nil {
+		return err
+	}
+	idx.read = true
+	idx.base = contentHash(content)
+	return nil
+}
+
+//content of a file, a missing file is empty
+func readFile(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return content, err
+}
+
+func contentHash(content []byte) []byte {
+	if len(content) == 0 {
+		return nil
+	}
+	hash := sha1.Sum(content)
+	return hash[:]
+}
+
+//All entries sorted by path and stage
+func (idx *Index) Entries() []Entry {
+	entries := make([]Entry, 0, len(idx.entries))
+	for _, entry := range idx.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].path != entries[j].path {
+			return entries[i].path < entries[j].path
+		}
+		return entries[i].stage < entries[j].stage
+	})
+	return entries
+}
+
+//Amount of entries including conflicting stages
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+//Entry of a path without conflicts
+func (idx *Index) Get(path string) (Entry, bool) {
+	entry, ok := idx.entries[entryKey{path: path, stage: Merged}]
+	return entry, ok
+}
+
+//Add entries or replace existing ones with the same path and stage
+//A merged entry resolves a conflict so all stages of its path are dropped,
+//an entry of a conflict stage replaces the merged entry of its path
+func (idx *Index) Add(entries ...Entry) {
+	for _, entry := range entries {
+		if entry.stage == Merged {
+			idx.remove(entry.path)
+		} else {
+			delete(idx.entries, entryKey{path: entry.path, stage: Merged})
+		}
+		idx.entries[entryKey{path: entry.path, stage: entry.stage}] = entry
+	}
+}
+
+//Remove all stages of given paths, returns the amount of removed entries
+func (idx *Index) Remove(paths ...string) int {
+	count := 0
+	for _, path := range paths {
+		count += idx.remove(path)
+	}
+	return count
+}
+
+//remove all stages of a path
+func (idx *Index) remove(path string) int {
+	count := 0
+	for _, stage := range []Stage{Merged, Base, Ours, Theirs} {
+		key := entryKey{path: path, stage: stage}
+		if _, ok := idx.entries[key]; ok {
+			delete(idx.entries, key)
+			count++
+		}
+	}
+	return count
+}
+
+//Remove all entries, an index that was read from a file is still only saved
+//if the file wasn't changed by another process
+func (idx *Index) Clear() {
+	idx.entries = make(map[entryKey]Entry)
+}
+
+//Paths that have entries in conflict stages
+func (idx *Index) Conflicts() []string {
+	var paths []string
+	for _, entry := range idx.Entries() {
+		if entry.stage != Merged && (len(paths) == 0 || paths[len(paths)-1] != entry.path) {
+			paths = append(paths, entry.path)
+		}
+	}
+	return paths
+}
+
+// +-------------------------------------------+
+// | Index format version 2                    |
+// +-------------------------------------------+
+// | "DIRC", version, amount of entries        |
+// | entries:                                  |
+// |   ctime, mtime (seconds and nanoseconds)  |
+// |   dev, ino, mode, uid, gid, size          |
+// |   20 bytes of hash                        |
+// |   flags: stage and length of the path     |
+// |   path padded with 1-8 null bytes         |
+// | extensions (skipped while reading)        |
+// | SHA-1 of everything above                 |
+// +-------------------------------------------+
+func (idx *Index) Encode() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString(signature)
+	writeUint32(&buffer, version)
+	entries := idx.Entries()
+	writeUint32(&buffer, uint32(len(entries)))
+	for _, entry := range entries {
+		mode, err := strconv.ParseUint(string(entry.mode), 8, 32)
+		if err != nil {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid mode '%s' of index entry '%s'", entry.mode, entry.path),
+			)
+		}
+		hash, err := hex.DecodeString(string(entry.hash))
+		if err != nil || len(hash) != sha1.Size {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid hash '%s' of index entry '%s'", entry.hash, entry.path),
+			)
+		}
+		stat := entry.stat
+		for _, field := range []uint32{
+			stat.CTime, stat.CTimeNano, stat.MTime, stat.MTimeNano,
+			stat.Dev, stat.Ino, uint32(mode), stat.UID, stat.GID, stat.Size,
+		} {
+			writeUint32(&buffer, field)
+		}
+		buffer.Write(hash)
+		nameLength := len(entry.path)
+		if nameLength > maxNameLength {
+			nameLength = maxNameLength
+		}
+		binary.Write(&buffer, binary.BigEndian, uint16(entry.stage)<<12|uint16(nameLength))
+		buffer.WriteString(entry.path)
+		//the path is followed by at least one null byte up to a multiple of 8
+		padding := 8 - (entryHeaderSize+len(entry.path))%8
+		buffer.Write(make([]byte, padding))
+	}
+	checksum := sha1.Sum(buffer.Bytes())
+	buffer.Write(checksum[:])
+	return buffer.Bytes(), nil
+}
+
+//Parse an index in the binary format, the checksum is verified
+func Decode(content []byte) (*Index, error) {
+	if len(content) < 12+sha1.Size || string(content[:4]) != signature {
+		return nil, errors.New("Invalid index file, there is no DIRC signature")
+	}
+	body := content[:len(content)-sha1.Size]
+	checksum := sha1.Sum(body)
+	if !bytes.Equal(checksum[:], content[len(body):]) {
+		return nil, errors.New("Invalid index file, the checksum doesn't match")
+	}
+	if v := binary.BigEndian.Uint32(body[4:8]); v != version {
+		return nil, errors.New(
+			fmt.Sprintf("Unsupported index version %d, only %d is supported", v, version),
+		)
+	}
+	count := binary.BigEndian.Uint32(body[8:12])
+	offset := 12
+	idx := New()
+	for i := uint32(0); i < count; i++ {
+		if offset+entryHeaderSize > len(body) {
+			return nil, errors.New("Invalid index file, an entry is truncated")
+		}
+		fields := make([]uint32, 10)
+		for j := range fields {
+			fields[j] = binary.BigEndian.Uint32(body[offset+j*4:])
+		}
+		hash := body[offset+40 : offset+40+sha1.Size]
+		flags := binary.BigEndian.Uint16(body[offset+40+sha1.Size:])
+		nameStart := offset + entryHeaderSize
+		nameEnd := bytes.IndexByte(body[nameStart:], 0)
+		if nameEnd == -1 {
+			return nil, errors.New("Invalid index file, a path is not terminated")
+		}
+		path := string(body[nameStart : nameStart+nameEnd])
+		mode, err := repository.AsMode(fmt.Sprintf("%o", fields[6]))
+		if err != nil {
+			return nil, err
+		}
+		idx.Add(
+			Entry{
+				path:  path,
+				mode:  mode,
+				hash:  repository.Hash(hex.EncodeToString(hash)),
+				stage: Stage(flags >> 12 & 0x3),
+				stat: Stat{
+					CTime:     fields[0],
+					CTimeNano: fields[1],
+					MTime:     fields[2],
+					MTimeNano: fields[3],
+					Dev:       fields[4],
+					Ino:       fields[5],
+					UID:       fields[7],
+					GID:       fields[8],
+					Size:      fields[9],
+				},
+			},
+		)
+		offset += entryHeaderSize + len(path) + 8 - (entryHeaderSize+len(path))%8
+	}
+	if offset > len(body) {
+		return nil, errors.New("Invalid index file, an entry is truncated")
+	}
+	return idx, nil
+}
+
+//Parse an index of older versions, one "<mode> <ctime> <mtime> <hash>\t<path>" line per file
+func decodeText(content string) (*Index, error) {
+	idx := New()
+	for _, line := range strings.Split(content, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		parts := strings.SplitN(line, "\t", 2)
+		fields := strings.Fields(parts[0])
+		if len(parts) != 2 || len(fields) != 4 {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid line for index '%s'", line),
+			)
+		}
+		mode, err := repository.AsMode(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		crTime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, errors.New("Invalid creation time, long expected")
+		}
+		modTime, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, errors.New("Invalid modification time, long expected")
+		}
+		hash, err := repository.NewHash(fields[3])
+		if err != nil {
+			return nil, err
+		}
+		//the rest of the stat data is unknown, such entries are compared by content
+		idx.Add(
+			NewEntry(parts[1], mode, hash, Stat{CTime: uint32(crTime), MTime: uint32(modTime)}),
+		)
+	}
+	return idx, nil
+}
+
+func writeUint32(buffer *bytes.Buffer, value uint32) {
+	binary.Write(buffer, binary.BigEndian, value)
+}
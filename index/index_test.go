@@ -0,0 +1,229 @@
+package index
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/repository"
+)
+
+//hash of blob "x" as computed by git
+const xBlob = repository.Hash("c1b0730e0133447badcfd47fd144e254807b06e1")
+
+func TestNewFileEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	file, err := ioutil.TempFile(dir, "tempFile")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	content := "Some file content"
+	file.WriteString(content)
+	file.Close()
+	store := repository.NewMemoryStore()
+	hash, err := store.Put(repository.BLOB, []byte(content))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	entry, err := NewFileEntry(file.Name(), repository.FILE, hash, store)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if entry.Hash() != hash {
+		t.Fatalf("Wrong entry hash, expected %s, got %s", hash, entry.Hash())
+	}
+	if entry.Path() != file.Name() {
+		t.Fatalf("Wrong entry path, expected %s, got %s", file.Name(), entry.Path())
+	}
+	if entry.Stat().Size != uint32(len(content)) || entry.Stat().MTime == 0 {
+		t.Fatalf("Stat data was not saved %+v", entry.Stat())
+	}
+	_, err = NewFileEntry(file.Name(), repository.FILE, xBlob, store)
+	if err == nil {
+		t.Fatal("Entry of a blob that doesn't exist should not be created")
+	}
+}
+
+func TestWriteAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	stat := Stat{CTime: 1, CTimeNano: 2, MTime: 3, MTimeNano: 4, Dev: 5, Ino: 6, UID: 7, GID: 8, Size: 9}
+	idx := New()
+	idx.Add(
+		NewEntry("src/main.go", repository.FILE, xBlob, stat),
+		NewEntry("README.md", repository.EXECUTABLE, xBlob, Stat{}),
+		//a path of 10 bytes fills the padding of an entry exactly
+		NewEntry("conflict.x", repository.FILE, xBlob, stat).WithStage(Ours),
+		NewEntry("conflict.x", repository.FILE, xBlob, stat).WithStage(Theirs),
+	)
+	indexPath := dir + repository.Index
+	err = idx.Write(indexPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	read, err := Read(indexPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	entries := read.Entries()
+	if len(entries) != 4 {
+		t.Fatalf("Wrong amount of entries, expected 4, got %d", len(entries))
+	}
+	//entries are sorted by path and stage
+	if entries[0].Path() != "README.md" || entries[0].Mode() != repository.EXECUTABLE {
+		t.Fatalf("Wrong first entry %s", entries[0])
+	}
+	if entries[1].Stage() != Ours || entries[2].Stage() != Theirs {
+		t.Fatalf("Wrong stages %s, %s", entries[1], entries[2])
+	}
+	if entries[3].Stat() != stat || entries[3].Hash() != xBlob {
+		t.Fatalf("Wrong stat data of entry %s: %+v", entries[3], entries[3].Stat())
+	}
+	conflicts := read.Conflicts()
+	if len(conflicts) != 1 || conflicts[0] != "conflict.x" {
+		t.Fatalf("Wrong conflicts %v", conflicts)
+	}
+}
+
+func TestDecode_corrupted(t *testing.T) {
+	idx := New()
+	idx.Add(NewEntry("file", repository.FILE, xBlob, Stat{}))
+	content, err := idx.Encode()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	content[20] ^= 0xFF
+	_, err = Decode(content)
+	if err == nil {
+		t.Fatal("Index with a wrong checksum should not be decoded")
+	}
+}
+
+func TestRead_textIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	indexPath := dir + repository.Index
+	text := "100644 10 20 " + string(xBlob) + "\tdir/with space.txt\n" +
+		"100755 30 40 " + string(xBlob) + "\tb.sh\n"
+	err = ioutil.WriteFile(indexPath, []byte(text), 0644)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	idx, err := Read(indexPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	entry, ok := idx.Get("dir/with space.txt")
+	if !ok {
+		t.Fatal("Entry with a space in the path was not read")
+	}
+	if entry.Stat().CTime != 10 || entry.Stat().MTime != 20 {
+		t.Fatalf("Wrong times of entry %s: %+v", entry, entry.Stat())
+	}
+	//the next write upgrades the index to the binary format
+	err = idx.Write(indexPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	content, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(content[:4]) != "DIRC" {
+		t.Fatalf("Index was not upgraded, got '%s'", content[:4])
+	}
+}
+
+func TestWrite_changedByAnotherProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	indexPath := dir + "/index"
+	first, err := Read(indexPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	second, err := Read(indexPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	first.Add(NewEntry("first", repository.FILE, xBlob, Stat{}))
+	err = first.Write(indexPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	//the index that was read before is stale now
+	second.Add(NewEntry("second", repository.FILE, xBlob, Stat{}))
+	err = second.Write(indexPath)
+	if err == nil {
+		t.Fatal("Index changed by another process should not be overwritten")
+	}
+	second.Clear()
+	err = second.Write(indexPath)
+	if err == nil {
+		t.Fatal("Cleared index should not overwrite changes of another process")
+	}
+	//the same index can be written again
+	first.Remove("first")
+	err = first.Write(indexPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if repository.Exists(indexPath + repository.LockExt) {
+		t.Fatal("Lock of the index was kept")
+	}
+}
+
+func TestAdd_resolvesConflicts(t *testing.T) {
+	idx := New()
+	idx.Add(NewEntry("file", repository.FILE, xBlob, Stat{}))
+	idx.Add(
+		NewEntry("file", repository.FILE, xBlob, Stat{}).WithStage(Base),
+		NewEntry("file", repository.FILE, xBlob, Stat{}).WithStage(Ours),
+	)
+	if _, ok := idx.Get("file"); ok || idx.Len() != 2 {
+		t.Fatalf("Conflict stages should replace the merged entry, got %v", idx.Entries())
+	}
+	idx.Add(NewEntry("file", repository.FILE, xBlob, Stat{}))
+	if len(idx.Conflicts()) != 0 || idx.Len() != 1 {
+		t.Fatalf("Merged entry should resolve the conflict, got %v", idx.Entries())
+	}
+	if removed := idx.Remove("file", "missing"); removed != 1 || idx.Len() != 0 {
+		t.Fatalf("Wrong amount of removed entries %d", removed)
+	}
+}
+
+func TestAdd_sortsEntries(t *testing.T) {
+	idx := New()
+	idx.Add(NewEntry("b", repository.FILE, xBlob, Stat{}))
+	idx.Add(
+		NewEntry("a/z", repository.FILE, xBlob, Stat{}).WithStage(Theirs),
+		NewEntry("a/z", repository.FILE, xBlob, Stat{}).WithStage(Base),
+		NewEntry("a", repository.FILE, xBlob, Stat{}),
+	)
+	idx.Add(NewEntry("b", repository.EXECUTABLE, xBlob, Stat{}))
+	var listed []string
+	for _, entry := range idx.Entries() {
+		listed = append(listed, fmt.Sprintf("%s:%d", entry.Path(), entry.Stage()))
+	}
+	if strings.Join(listed, ",") != "a:0,a/z:1,a/z:3,b:0" {
+		t.Fatalf("Entries should be sorted by path and stage, got %v", listed)
+	}
+	if entry, _ := idx.Get("b"); entry.Mode() != repository.EXECUTABLE {
+		t.Fatalf("Entry with the same path and stage should be replaced, got %v", entry)
+	}
+}
@@ -1,36 +1,67 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
+	"path/filepath"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/olekukonko/tablewriter"
 	"github.com/strogiyotec/dzhigit/cli"
+	"github.com/strogiyotec/dzhigit/index"
+	"github.com/strogiyotec/dzhigit/pack"
 	"github.com/strogiyotec/dzhigit/repository"
 )
 
 func main() {
 	ctx := kong.Parse(&cli.Git)
 	switch ctx.Command() {
-	case "init":
+	case "init", "init <dir>":
 		{
-			path := repository.DefaultPath()
+			options := cli.Git.Init
 			json, err := cli.DefaultGitUserAsJson()
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
-			err = repository.Init(path, json)
+			dir := options.Dir
+			if len(dir) == 0 {
+				dir, err = os.Getwd()
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+			}
+			if options.Bare {
+				err = repository.InitBare(dir, json)
+			} else {
+				err = repository.Init(filepath.Join(dir, repository.RepoDir), json)
+			}
 			if err != nil {
 				fmt.Println(err.Error())
 			} else {
 				fmt.Println("Initialize new dzhigit repository")
 			}
 		}
+	case "add <files>":
+		{
+			repo := openWorkTree()
+			entries, err := cli.Add(
+				repo.Path,
+				repo.WorkTree,
+				cli.Git.Add.Files,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			for _, entry := range entries {
+				fmt.Printf("add '%s'\n", entry.Path())
+			}
+		}
 	case "hash-object <file>":
 		{
 			gitFile := repository.DefaultGitFileFormatter{}
@@ -52,18 +83,13 @@ func main() {
 				return
 			}
 			if cli.Git.HashObject.Write {
-				path := repository.DefaultPath()
-				if !repository.Exists(path) {
-					fmt.Println("Dzhigit repository doesn't exist")
-					return
-				}
-				objPath := repository.ObjPath(path)
-				err = gitFile.Save(serialized, objPath)
+				repo := openRepository()
+				hash, err := repo.Objects.Put(objType, content)
 				if err != nil {
 					fmt.Println(err.Error())
 					return
 				}
-				fmt.Println(serialized.Hash)
+				fmt.Println(hash)
 				fmt.Println("The file with given hash was saved")
 			} else {
 				fmt.Println(serialized.Hash)
@@ -71,33 +97,36 @@ func main() {
 		}
 	case "cat-file <hash>":
 		{
-			path := repository.DefaultPath()
-			if !repository.Exists(path) {
-				fmt.Println("Dzhigit repository doesn't exist")
-				return
-			}
-			gitFile := &repository.DefaultGitFileFormatter{}
-			objPath := repository.ObjPath(path)
-			hash, err := repository.NewHash(cli.Git.CatFile.Hash)
+			repo := openRepository()
+			hash, err := cli.ResolveRevision(
+				repo.Path,
+				cli.Git.CatFile.Hash,
+				repository.Reader,
+				repo.Objects,
+			)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
-			deser, err := cli.GitCat(hash, gitFile, objPath, repository.Reader)
+			deser, err := cli.GitCat(hash, repo.Objects)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
+			if deser.ObjType == repository.TREE {
+				tree, err := cli.PrettyTree(deser.Content)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				fmt.Print(tree)
+				return
+			}
 			fmt.Println(deser.Content)
 		}
 	case "update-index <hash> <file> <mode>":
 		{
-			gitRepoPath := repository.DefaultPath()
-			if !repository.Exists(gitRepoPath) {
-				fmt.Println("Dzhigit repository doesn't exist")
-				return
-			}
-			objPath := repository.ObjPath(gitRepoPath)
+			repo := openRepository()
 			indexParams := cli.Git.UpdateIndex
 			mode, err := repository.AsMode(indexParams.Mode)
 			if err != nil {
@@ -109,12 +138,12 @@ func main() {
 				fmt.Println(err.Error())
 				return
 			}
-			index, err := repository.NewIndex(indexParams.File, mode, hash, objPath)
+			entry, err := index.NewFileEntry(indexParams.File, mode, hash, repo.Objects)
 			if err != nil {
 				fmt.Println(err.Error())
 			} else {
-				indexPath := repository.IndexPath(gitRepoPath)
-				err := cli.UpdateIndex(*index, indexPath)
+				indexPath := repository.IndexPath(repo.Path)
+				err := cli.UpdateIndex(*entry, indexPath)
 				if err != nil {
 					fmt.Println(err.Error())
 				} else {
@@ -124,59 +153,39 @@ func main() {
 		}
 	case "ls-tree":
 		{
-			//TODO: move this check above all switch cases
-			gitRepoPath := repository.DefaultPath()
-			if !repository.Exists(gitRepoPath) {
-				fmt.Println("Dzhigit repository doesn't exist")
-				return
-			}
-			indexPath := repository.IndexPath(gitRepoPath)
-			file, err := os.Open(indexPath)
+			repo := openRepository()
+			idx, err := index.Read(repository.IndexPath(repo.Path))
 			if err != nil {
-				fmt.Printf("Error opening index file %s", err.Error())
+				fmt.Println(err.Error())
 				return
 			}
-			defer file.Close()
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				fmt.Println(scanner.Text())
+			for _, entry := range idx.Entries() {
+				fmt.Println(entry.String())
 			}
 		}
 	case "write-tree":
 		{
-			gitRepoPath := repository.DefaultPath()
-			if !repository.Exists(gitRepoPath) {
-				fmt.Println("Dzhigit repository doesn't exist")
-				return
-			}
-			indexPath := repository.IndexPath(gitRepoPath)
-			indexContent, err := ioutil.ReadFile(indexPath)
+			repo := openRepository()
+			idx, err := index.Read(repository.IndexPath(repo.Path))
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
-			lines := strings.Split(strings.TrimSpace(string(indexContent)), "\n")
-			objPath := repository.ObjPath(gitRepoPath)
 			tree, err := cli.WriteTree(
-				lines,
-				objPath,
-				&repository.DefaultGitFileFormatter{},
+				idx,
+				repo.Objects,
 			)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			} else {
-				fmt.Println(tree.Hash)
+				fmt.Println(tree)
 			}
 		}
 	case "commit-tree <hash>":
 		{
-			gitRepoPath := repository.DefaultPath()
-			if !repository.Exists(gitRepoPath) {
-				fmt.Println("Dzhigit repository doesn't exist")
-				return
-			}
-			content, err := os.ReadFile(repository.ConfigPath(gitRepoPath))
+			repo := openRepository()
+			content, err := os.ReadFile(repository.ConfigPath(repo.Path))
 			if err != nil {
 				fmt.Printf("Error reading a config file %s", err.Error())
 				return
@@ -190,14 +199,24 @@ func main() {
 				return
 			}
 			time := cli.CurrentTime()
-			treeHash, err := repository.NewHash(cli.Git.CommitTree.Hash)
+			treeHash, err := cli.ResolveRevision(
+				repo.Path,
+				cli.Git.CommitTree.Hash,
+				repository.Reader,
+				repo.Objects,
+			)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
 			var parentHash repository.Hash
 			if len(cli.Git.CommitTree.Parent) != 0 {
-				parentHash, err = repository.NewHash(cli.Git.CommitTree.Parent)
+				parentHash, err = cli.ResolveCommit(
+					repo.Path,
+					cli.Git.CommitTree.Parent,
+					repository.Reader,
+					repo.Objects,
+				)
 				if err != nil {
 					fmt.Println(err.Error())
 					return
@@ -210,124 +229,734 @@ func main() {
 				user,
 				time,
 			)
-			repo := &repository.DefaultGitFileFormatter{}
-			objPath := repository.ObjPath(gitRepoPath)
-			ser, err := cli.CommitTree(
+			hash, err := cli.CommitTree(
 				*commit,
-				objPath,
-				repo,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			fmt.Println(hash)
+		}
+	case "commit":
+		{
+			repo := openRepository()
+			content, err := os.ReadFile(repository.ConfigPath(repo.Path))
+			if err != nil {
+				fmt.Printf("Error reading a config file %s", err.Error())
+				return
+			}
+			user, err := cli.NewUser(content)
+			if err != nil {
+				fmt.Printf(
+					"Error reading a user's data from config file %s",
+					err.Error(),
+				)
+				return
+			}
+			options := cli.Git.Commit
+			hash, err := cli.CommitIndex(
+				repo.Path,
+				options.Message,
+				options.AllowEmpty,
+				options.Amend,
+				user,
+				cli.CurrentTime(),
+				repository.Reader,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			fmt.Println(hash)
+		}
+	case "update-ref <name> <hash>":
+		{
+			options := cli.Git.UpdateRef
+			repo := openRepository()
+			treeHash, err := cli.ResolveCommit(
+				repo.Path,
+				options.Hash,
 				repository.Reader,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			err = cli.UpdateBranch(
+				repo.Path,
+				options.Name,
+				treeHash,
+				repository.Reader,
+				repo.Objects,
 			)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			} else {
-				err := repo.Save(ser, objPath)
+				fmt.Printf("Branch %s was created", options.Name)
+			}
+		}
+	case "checkout <branch>":
+		{
+			repo := openWorkTree()
+			options := cli.Git.Checkout
+			branch, err := cli.ResolveBranchName(repo.Path, options.Branch, repository.Reader)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			err = cli.Checkout(
+				repo.Path,
+				repo.WorkTree,
+				branch,
+				options.Force,
+				repository.Reader,
+				repo.Objects,
+				&repository.DefaultGitFileFormatter{},
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			if _, err := cli.Branch(repo.Path, repository.Reader); err != nil {
+				description, err := cli.HeadDescription(repo.Path, repository.Reader)
 				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				fmt.Println(description)
+				return
+			}
+			fmt.Printf("Branch %s was checkout\n", branch)
+		}
+	case "branch":
+		{
+			repo := openRepository()
+			branches, err := cli.ListBranches(
+				repo.Path,
+				repository.Reader,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			//a branch without commits doesn't exist yet and isn't listed like in git
+			for _, branch := range branches {
+				marker := " "
+				if branch.Current {
+					marker = "*"
+				}
+				if cli.Git.Branch.Verbose {
 					fmt.Printf(
-						"Error saving a commit object %s",
+						"%s %s %s %s\n",
+						marker,
+						branch.Name,
+						string(branch.Commit)[0:7],
+						branch.Subject,
+					)
+				} else {
+					fmt.Printf("%s %s\n", marker, branch.Name)
+				}
+			}
+		}
+	case "branch <name>", "branch <name> <start>":
+		{
+			repo := openRepository()
+			options := cli.Git.Branch
+			switch {
+			case options.Delete || options.ForceDelete:
+				tip, err := cli.DeleteBranch(
+					repo.Path,
+					options.Name,
+					options.ForceDelete,
+					repository.Reader,
+					repo.Objects,
+				)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				fmt.Printf("Deleted branch %s (was %s)\n", options.Name, string(tip)[0:7])
+			case options.Move:
+				oldName, newName := options.Name, options.Start
+				//with a single name the current branch is renamed
+				if len(newName) == 0 {
+					current, err := cli.Branch(repo.Path, repository.Reader)
+					if err != nil {
+						fmt.Println(err.Error())
+						return
+					}
+					oldName, newName = current, options.Name
+				}
+				err := cli.RenameBranch(repo.Path, oldName, newName, repository.Reader)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				fmt.Printf("Branch %s was renamed to %s\n", oldName, newName)
+			default:
+				err := cli.CreateBranch(
+					repo.Path,
+					options.Name,
+					options.Start,
+					repository.Reader,
+					repo.Objects,
+				)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				fmt.Printf("Branch %s was created\n", options.Name)
+			}
+		}
+	case "log":
+		{
+			repo := openRepository()
+			if cli.Git.Log.Patch {
+				err := cli.LogPatch(
+					os.Stdout,
+					repo.Path,
+					cli.Git.Log.Unified,
+					cli.Git.Log.Decorate,
+					repository.Reader,
+					repo.Objects,
+				)
+				if err != nil {
+					fmt.Println(err.Error())
+				}
+				return
+			}
+			description, err := cli.HeadDescription(repo.Path, repository.Reader)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"commit hash", "commit message", "author", "time"})
+			table.SetRowLine(true)
+			table.SetCaption(true, description)
+			err = cli.Log(
+				table,
+				repo.Path,
+				cli.Git.Log.Decorate,
+				repository.Reader,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			table.Render()
+		}
+	case "tag":
+		{
+			repo := openRepository()
+			tags, err := cli.ListTags(repo.Path)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			for _, tag := range tags {
+				fmt.Println(tag)
+			}
+		}
+	case "tag <name>", "tag <name> <object>":
+		{
+			repo := openRepository()
+			options := cli.Git.Tag
+			switch {
+			case options.Delete:
+				hash, err := cli.DeleteTag(repo.Path, options.Name, repository.Reader)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				fmt.Printf("Deleted tag '%s' (was %s)\n", options.Name, string(hash)[0:7])
+			case options.Show:
+				err := cli.ShowTag(
+					os.Stdout,
+					repo.Path,
+					options.Name,
+					repository.Reader,
+					repo.Objects,
+				)
+				if err != nil {
+					fmt.Println(err.Error())
+				}
+			case options.Annotate && len(options.Message) == 0:
+				fmt.Println("An annotated tag needs a message, use -m")
+			default:
+				content, err := os.ReadFile(repository.ConfigPath(repo.Path))
+				if err != nil {
+					fmt.Printf("Error reading a config file %s", err.Error())
+					return
+				}
+				user, err := cli.NewUser(content)
+				if err != nil {
+					fmt.Printf(
+						"Error reading a user's data from config file %s",
 						err.Error(),
 					)
 					return
-				} else {
-					fmt.Println(ser.Hash)
 				}
+				hash, err := cli.CreateTag(
+					repo.Path,
+					options.Name,
+					options.Object,
+					options.Message,
+					options.Force,
+					user,
+					cli.CurrentTime(),
+					repository.Reader,
+					repo.Objects,
+				)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				fmt.Printf("Tag %s was created %s\n", options.Name, hash)
 			}
 		}
-	case "update-ref <name> <hash>":
+	case "reset", "reset <revision>", "reset <revision> <paths>":
 		{
-			options := cli.Git.UpdateRef
-			formatter := repository.DefaultGitFileFormatter{}
-			gitRepoPath := repository.DefaultPath()
-			if !repository.Exists(gitRepoPath) {
-				fmt.Println("Dzhigit repository doesn't exist")
+			repo := openRepository()
+			options := cli.Git.Reset
+			//only a soft reset works without a working tree like in git
+			if !options.Soft {
+				err := repo.CheckWorkTree()
+				if err != nil {
+					fmt.Println(err.Error())
+					os.Exit(128)
+				}
+			}
+			//kong binds the first argument to the revision even after "--"
+			var args []string
+			if len(options.Revision) != 0 {
+				args = append([]string{options.Revision}, options.Paths...)
+			}
+			revision, paths := cli.ResetArguments(
+				repo.Path,
+				args,
+				separatedArguments("reset", options.Revision),
+				repository.Reader,
+				repo.Objects,
+			)
+			if len(paths) != 0 {
+				if options.Soft || options.Hard {
+					fmt.Println("Cannot do a soft or a hard reset with paths")
+					return
+				}
+				reset, err := cli.ResetPaths(
+					repo.Path,
+					repo.WorkTree,
+					revision,
+					paths,
+					repository.Reader,
+					repo.Objects,
+				)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				for _, path := range reset {
+					fmt.Printf("Unstaged '%s'\n", path)
+				}
 				return
 			}
-			objPath := repository.ObjPath(gitRepoPath)
-			treeHash, err := repository.NewHash(options.Hash)
+			mode := cli.MixedReset
+			if options.Soft {
+				mode = cli.SoftReset
+			} else if options.Hard {
+				mode = cli.HardReset
+			}
+			hash, err := cli.Reset(
+				repo.Path,
+				repo.WorkTree,
+				revision,
+				mode,
+				&repository.DefaultGitFileFormatter{},
+				repository.Reader,
+				repo.Objects,
+			)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
-			headsPath := repository.HeadsPath(gitRepoPath)
-			f, err := os.OpenFile(
-				headsPath+options.Name,
-				os.O_RDWR|os.O_CREATE|os.O_TRUNC,
-				0755,
+			fmt.Printf("HEAD is now at %s\n", string(hash)[0:7])
+		}
+	case "reflog", "reflog <ref>":
+		{
+			repo := openRepository()
+			name := cli.Git.Reflog.Ref
+			if len(name) == 0 {
+				name = "HEAD"
+			}
+			entries, err := cli.ReadReflog(
+				repo.Path,
+				cli.ReflogRef(name),
+				repository.Reader,
 			)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
-			err = cli.UpdateRef(treeHash, f, objPath, repository.Reader, &formatter)
+			for i, entry := range entries {
+				fmt.Printf("%s %s@{%d}: %s\n", string(entry.New)[0:7], name, i, entry.Message)
+			}
+		}
+	case "status":
+		{
+			repo := openWorkTree()
+			status, err := cli.GitStatus(
+				repo.Path,
+				repo.WorkTree,
+				&repository.DefaultGitFileFormatter{},
+				repository.Reader,
+				repo.Objects,
+			)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
+			}
+			if cli.Git.Status.Porcelain {
+				fmt.Print(status.Porcelain())
 			} else {
-				fmt.Printf("Branch %s was created", options.Name)
+				fmt.Print(status.String())
 			}
 		}
-	case "checkout <branch>":
+	case "diff", "diff <commits>":
 		{
-			gitRepoPath := repository.DefaultPath()
-			if !repository.Exists(gitRepoPath) {
-				fmt.Println("Dzhigit repository doesn't exist")
+			repo := openRepository()
+			options := cli.Git.Diff
+			formatter := &repository.DefaultGitFileFormatter{}
+			var err error
+			switch {
+			case len(options.Commits) == 2:
+				var hashes []repository.Hash
+				for _, commit := range options.Commits {
+					hash, err := cli.ResolveCommit(
+						repo.Path,
+						commit,
+						repository.Reader,
+						repo.Objects,
+					)
+					if err != nil {
+						fmt.Println(err.Error())
+						return
+					}
+					hashes = append(hashes, hash)
+				}
+				err = cli.DiffCommits(
+					os.Stdout,
+					hashes[0],
+					hashes[1],
+					options.Unified,
+					repo.Objects,
+				)
+			case len(options.Commits) != 0:
+				fmt.Println("Two commits are expected to compare")
 				return
+			case options.Cached:
+				err = cli.DiffCached(
+					os.Stdout,
+					repo.Path,
+					options.Unified,
+					repository.Reader,
+					repo.Objects,
+				)
+			default:
+				err = repo.CheckWorkTree()
+				if err != nil {
+					break
+				}
+				err = cli.DiffWorkTree(
+					os.Stdout,
+					repo.Path,
+					repo.WorkTree,
+					options.Unified,
+					formatter,
+					repo.Objects,
+				)
 			}
-			options := cli.Git.Checkout
-			objPath := repository.ObjPath(gitRepoPath)
-			err := cli.Checkout(
-				gitRepoPath,
-				options.Branch,
-				objPath,
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+		}
+	case "merge <branch>":
+		{
+			repo := openWorkTree()
+			content, err := os.ReadFile(repository.ConfigPath(repo.Path))
+			if err != nil {
+				fmt.Printf("Error reading a config file %s", err.Error())
+				return
+			}
+			user, err := cli.NewUser(content)
+			if err != nil {
+				fmt.Printf(
+					"Error reading a user's data from config file %s",
+					err.Error(),
+				)
+				return
+			}
+			result, err := cli.Merge(
+				repo.Path,
+				repo.WorkTree,
+				cli.Git.Merge.Branch,
+				user,
+				cli.CurrentTime(),
+				&repository.DefaultGitFileFormatter{},
 				repository.Reader,
-				repository.ObjReader,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			switch {
+			case result.UpToDate:
+				fmt.Println("Already up to date.")
+			case result.FastForward:
+				fmt.Printf("Fast-forward to %s\n", result.Commit)
+			case len(result.Conflicts) != 0:
+				for _, path := range result.Conflicts {
+					fmt.Printf("CONFLICT: Merge conflict in %s\n", path)
+				}
+				fmt.Println("Automatic merge failed; fix conflicts and then commit the result.")
+			default:
+				fmt.Printf("Merge made by the 'three-way' strategy %s\n", result.Commit)
+			}
+		}
+	case "rm <paths>":
+		{
+			repo := openWorkTree()
+			options := cli.Git.Rm
+			removed, err := cli.Remove(
+				repo.Path,
+				repo.WorkTree,
+				options.Paths,
+				options.Cached,
+				options.Recursive,
+				options.Force,
 				&repository.DefaultGitFileFormatter{},
+				repository.Reader,
+				repo.Objects,
 			)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
-			fmt.Printf("Branch %s was checkout\n", options.Branch)
+			for _, path := range removed {
+				fmt.Printf("rm '%s'\n", path)
+			}
 		}
-	case "branch":
+	case "migrate-objects":
 		{
-			gitRepoPath := repository.DefaultPath()
-			if !repository.Exists(gitRepoPath) {
-				fmt.Println("Dzhigit repository doesn't exist")
+			repo := openRepository()
+			changed, err := cli.MigrateObjects(
+				repo.Path,
+				repository.Reader,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
 				return
 			}
-			branch, err := cli.Branch(gitRepoPath, repository.Reader)
+			fmt.Printf("Rewrote %d objects in the format of git\n", len(changed))
+		}
+	case "check-ignore <paths>":
+		{
+			repo := openWorkTree()
+			options := cli.Git.CheckIgnore
+			matches, err := cli.CheckIgnore(repo.Path, repo.WorkTree, options.Paths)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
-			fmt.Printf("* %s\n", branch)
+			workTree := repo.WorkTree
+			for _, match := range matches {
+				if options.Verbose {
+					//negated patterns are shown too to explain why a path is not ignored
+					fmt.Println(match.Verbose(workTree))
+				} else if match.Ignored() {
+					fmt.Println(match.Path)
+				}
+			}
 		}
-	case "log":
+	case "pack-objects", "pack-objects <objects>":
 		{
-			gitRepoPath := repository.DefaultPath()
-			if !repository.Exists(gitRepoPath) {
-				fmt.Println("Dzhigit repository doesn't exist")
+			repo := openRepository()
+			options := cli.Git.PackObjects
+			var hashes []repository.Hash
+			for _, object := range options.Objects {
+				hash, err := cli.ResolveRevision(
+					repo.Path,
+					object,
+					repository.Reader,
+					repo.Objects,
+				)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				hashes = append(hashes, hash)
+			}
+			packPath, err := cli.PackObjects(
+				repo.Objects,
+				hashes,
+				pack.Options{Window: options.Window, Depth: options.Depth, RefDelta: options.RefDelta},
+			)
+			if err != nil {
+				fmt.Println(err.Error())
 				return
 			}
-			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader([]string{"commit hash", "commit message", "author", "time"})
-			table.SetRowLine(true)
-			err := cli.Log(
-				table,
-				gitRepoPath,
-				&repository.DefaultGitFileFormatter{},
+			fmt.Println(packPath)
+		}
+	case "index-pack <pack>":
+		{
+			checksum, err := cli.IndexPack(cli.Git.IndexPack.Pack)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			fmt.Println(checksum)
+		}
+	case "verify-pack <packs>":
+		{
+			options := cli.Git.VerifyPack
+			for _, path := range options.Packs {
+				err := cli.VerifyPack(os.Stdout, path, options.Verbose)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+			}
+		}
+	case "gc":
+		{
+			repo := openRepository()
+			result, err := cli.GC(
+				repo.Path,
+				time.Now().Add(-cli.Git.Gc.Expire),
 				repository.Reader,
-				repository.ObjReader,
+				repo.Objects,
 			)
 			if err != nil {
 				fmt.Println(err.Error())
 				return
 			}
-			table.Render()
+			if len(result.Pack) != 0 {
+				fmt.Printf("Packed %d objects into %s\n", result.Packed, result.Pack)
+			}
+			fmt.Printf("Pruned %d unreachable objects\n", len(result.Pruned))
+		}
+	case "prune":
+		{
+			repo := openRepository()
+			options := cli.Git.Prune
+			pruned, err := cli.Prune(
+				repo.Path,
+				time.Now().Add(-options.Expire),
+				options.DryRun,
+				repository.Reader,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			for _, hash := range pruned {
+				fmt.Println(hash)
+			}
+		}
+	case "count-objects":
+		{
+			repo := openRepository()
+			count, err := cli.CountObjects(repo.Objects)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+			if cli.Git.CountObjects.Verbose {
+				fmt.Println(count.Verbose())
+			} else {
+				fmt.Println(count.String())
+			}
+		}
+	case "fsck":
+		{
+			repo := openRepository()
+			report, err := cli.Fsck(
+				repo.Path,
+				&repository.DefaultGitFileFormatter{},
+				repository.Reader,
+				repo.Objects,
+			)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(128)
+			}
+			if cli.Git.Fsck.JSON {
+				content, err := report.JSON()
+				if err != nil {
+					fmt.Println(err.Error())
+					os.Exit(128)
+				}
+				fmt.Println(string(content))
+			} else {
+				for _, problem := range report.Problems {
+					fmt.Println(problem.String())
+				}
+			}
+			os.Exit(report.ExitCode)
 		}
 	default:
 		fmt.Println("Default")
 	}
 }
+
+//Check if "--" is given before the first argument of a command, e.g. "reset -- file"
+func separatedArguments(command string, first string) bool {
+	args := os.Args[1:]
+	for i, arg := range args {
+		if arg == command {
+			args = args[i+1:]
+			break
+		}
+	}
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return true
+		case first:
+			return false
+		}
+	}
+	return false
+}
+
+//Open the repository of the current directory, exits like git does if there is none
+func openRepository() *repository.Repository {
+	repo, err := repository.Open(".")
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(128)
+	}
+	return repo
+}
+
+//Open the repository of the current directory for a command that needs a working tree
+func openWorkTree() *repository.Repository {
+	repo := openRepository()
+	err := repo.CheckWorkTree()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(128)
+	}
+	return repo
+}
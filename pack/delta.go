@@ -0,0 +1,209 @@
+package pack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	//length of blocks of a base that are indexed to find matches
+	blockSize = 16
+	//the longest literal that a single insert instruction can hold
+	maxInsert = 0x7f
+	//the longest copy that fits the three size bytes of a copy instruction
+	maxCopy = 0xffffff
+	//offsets of the same block that are remembered, the latest ones are kept
+	maxCandidates = 8
+)
+
+// +---------------------------------------------------+
+// | Delta format                                      |
+// +---------------------------------------------------+
+// | size of the base, size of the result (varints)    |
+// | instructions:                                     |
+// |   1xxxxxxx offset bytes, size bytes - copy        |
+// |            a range of the base                    |
+// |   0nnnnnnn n bytes - insert n literal bytes       |
+// +---------------------------------------------------+
+//Create a delta that turns base into target
+//Blocks of the base are indexed by content, the target is scanned for
+//these blocks and every match is extended as far as both sides agree
+func CreateDelta(base []byte, target []byte) []byte {
+	var delta bytes.Buffer
+	writeVarint(&delta, uint64(len(base)))
+	writeVarint(&delta, uint64(len(target)))
+	blocks := make(map[string][]int)
+	for offset := 0; offset+blockSize <= len(base); offset += blockSize {
+		key := string(base[offset : offset+blockSize])
+		candidates := append(blocks[key], offset)
+		if len(candidates) > maxCandidates {
+			candidates = candidates[1:]
+		}
+		blocks[key] = candidates
+	}
+	var literal []byte
+	position := 0
+	for position < len(target) {
+		matchOffset, matchLength := 0, 0
+		if position+blockSize <= len(target) {
+			for _, candidate := range blocks[string(target[position:position+blockSize])] {
+				length := blockSize
+				for candidate+length < len(base) &&
+					position+length < len(target) &&
+					base[candidate+length] == target[position+length] {
+					length++
+				}
+				if length > matchLength {
+					matchOffset, matchLength = candidate, length
+				}
+			}
+		}
+		if matchLength == 0 {
+			literal = append(literal, target[position])
+			position++
+			continue
+		}
+		end := position + matchLength
+		//bytes before the block may match as well, they are taken from the pending literal
+		for len(literal) > 0 && matchOffset > 0 &&
+			base[matchOffset-1] == literal[len(literal)-1] {
+			literal = literal[:len(literal)-1]
+			matchOffset--
+			matchLength++
+		}
+		writeInsert(&delta, literal)
+		literal = literal[:0]
+		writeCopy(&delta, matchOffset, matchLength)
+		position = end
+	}
+	writeInsert(&delta, literal)
+	return delta.Bytes()
+}
+
+//Apply a delta to the base and return the resulting object
+func ApplyDelta(base []byte, delta []byte) ([]byte, error) {
+	reader := bytes.NewReader(delta)
+	baseSize, err := readVarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, errors.New(
+			fmt.Sprintf("Delta expects a base of %d bytes, got %d", baseSize, len(base)),
+		)
+	}
+	resultSize, err := readVarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, 0, resultSize)
+	for reader.Len() > 0 {
+		instruction, _ := reader.ReadByte()
+		if instruction&0x80 == 0 {
+			if instruction == 0 {
+				return nil, errors.New("Invalid delta, unexpected instruction 0")
+			}
+			literal := make([]byte, instruction)
+			if n, _ := reader.Read(literal); n != len(literal) {
+				return nil, errors.New("Invalid delta, insert instruction is truncated")
+			}
+			result = append(result, literal...)
+			continue
+		}
+		var offset, size uint64
+		for i := uint(0); i < 7; i++ {
+			if instruction&(1<<i) == 0 {
+				continue
+			}
+			b, err := reader.ReadByte()
+			if err != nil {
+				return nil, errors.New("Invalid delta, copy instruction is truncated")
+			}
+			if i < 4 {
+				offset |= uint64(b) << (8 * i)
+			} else {
+				size |= uint64(b) << (8 * (i - 4))
+			}
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > uint64(len(base)) {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid delta, copy of %d bytes at %d is out of the base", size, offset),
+			)
+		}
+		result = append(result, base[offset:offset+size]...)
+	}
+	if uint64(len(result)) != resultSize {
+		return nil, errors.New(
+			fmt.Sprintf("Delta produced %d bytes, %d expected", len(result), resultSize),
+		)
+	}
+	return result, nil
+}
+
+//insert instructions for a literal, long literals are split
+func writeInsert(delta *bytes.Buffer, literal []byte) {
+	for len(literal) > 0 {
+		size := len(literal)
+		if size > maxInsert {
+			size = maxInsert
+		}
+		delta.WriteByte(byte(size))
+		delta.Write(literal[:size])
+		literal = literal[size:]
+	}
+}
+
+//copy instructions for a range of the base, only non zero bytes of offset and size are written
+func writeCopy(delta *bytes.Buffer, offset int, length int) {
+	for length > 0 {
+		size := length
+		if size > maxCopy {
+			size = maxCopy
+		}
+		instruction := byte(0x80)
+		var args []byte
+		for i := uint(0); i < 4; i++ {
+			if b := byte(offset >> (8 * i)); b != 0 {
+				instruction |= 1 << i
+				args = append(args, b)
+			}
+		}
+		for i := uint(0); i < 3; i++ {
+			if b := byte(size >> (8 * i)); b != 0 {
+				instruction |= 1 << (4 + i)
+				args = append(args, b)
+			}
+		}
+		delta.WriteByte(instruction)
+		delta.Write(args)
+		offset += size
+		length -= size
+	}
+}
+
+//little endian base 128 number used for sizes in a delta
+func writeVarint(buffer *bytes.Buffer, value uint64) {
+	for value >= 0x80 {
+		buffer.WriteByte(byte(value) | 0x80)
+		value >>= 7
+	}
+	buffer.WriteByte(byte(value))
+}
+
+func readVarint(reader *bytes.Reader) (uint64, error) {
+	var value uint64
+	for shift := uint(0); ; shift += 7 {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, errors.New("Invalid delta, size is truncated")
+		}
+		value |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+}
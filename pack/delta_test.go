@@ -0,0 +1,56 @@
+package pack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCreateDelta(t *testing.T) {
+	base := []byte(strings.Repeat("package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n", 20))
+	cases := []struct {
+		name   string
+		target []byte
+	}{
+		{"same content", base},
+		{"appended line", append(append([]byte{}, base...), "//the end\n"...)},
+		{"prepended line", append([]byte("//the start\n"), base...)},
+		{"changed middle", bytes.Replace(base, []byte("hello"), []byte("world"), 3)},
+		{"nothing in common", []byte("completely different content")},
+		{"empty target", []byte{}},
+		{"long literal", bytes.Repeat([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 100)},
+	}
+	for _, c := range cases {
+		delta := CreateDelta(base, c.target)
+		result, err := ApplyDelta(base, delta)
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err.Error())
+		}
+		if !bytes.Equal(result, c.target) {
+			t.Fatalf("%s: delta produced a different object", c.name)
+		}
+	}
+	//a small change of a big object is a small delta
+	delta := CreateDelta(base, append(append([]byte{}, base...), "//the end\n"...))
+	if len(delta) > 32 {
+		t.Fatalf("Delta of an appended line is too big, %d bytes", len(delta))
+	}
+}
+
+func TestApplyDelta_invalid(t *testing.T) {
+	base := []byte("hello world")
+	cases := []struct {
+		name  string
+		delta []byte
+	}{
+		{"wrong base size", []byte{5, 5, 0x90, 5}},
+		{"copy out of base", []byte{11, 20, 0x91, 5, 20}},
+		{"truncated insert", []byte{11, 5, 5, 'a'}},
+		{"wrong result size", []byte{11, 3, 0x90, 5}},
+	}
+	for _, c := range cases {
+		if _, err := ApplyDelta(base, c.delta); err == nil {
+			t.Fatalf("%s: invalid delta was applied", c.name)
+		}
+	}
+}
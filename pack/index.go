@@ -0,0 +1,224 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	indexSignature = "\377tOc"
+	indexVersion   = 2
+	//offsets that don't fit 31 bits are stored in a table of 64 bit offsets
+	largeOffset = 0x80000000
+)
+
+//location of an object in a pack
+type IndexEntry struct {
+	Hash   string
+	Offset int64
+	CRC    uint32 //checksum of the compressed entry in the pack
+}
+
+//index of a pack, entries are sorted by hash
+type Index struct {
+	entries      []IndexEntry
+	fanout       [256]uint32
+	PackChecksum []byte
+}
+
+// +-------------------------------------------+
+// | Pack index format version 2               |
+// +-------------------------------------------+
+// | "\377tOc", version                        |
+// | fanout: 256 amounts of objects which      |
+// |   first byte of hash is <= i              |
+// | sorted hashes (20 bytes each)             |
+// | CRC32 of each entry                       |
+// | 31 bit offsets, MSB marks a large offset  |
+// | 64 bit large offsets                      |
+// | checksum of the pack                      |
+// | SHA-1 of everything above                 |
+// +-------------------------------------------+
+func EncodeIndex(entries []IndexEntry, packChecksum []byte) ([]byte, error) {
+	sorted := make([]IndexEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Hash < sorted[j].Hash
+	})
+	var fanout [256]uint32
+	hashes := make([][]byte, len(sorted))
+	for i, entry := range sorted {
+		hash, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(hash) != sha1.Size {
+			return nil, errors.New(fmt.Sprintf("Invalid hash '%s' of a packed object", entry.Hash))
+		}
+		hashes[i] = hash
+		for b := int(hash[0]); b < len(fanout); b++ {
+			fanout[b]++
+		}
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(indexSignature)
+	binary.Write(&buffer, binary.BigEndian, uint32(indexVersion))
+	binary.Write(&buffer, binary.BigEndian, fanout)
+	for _, hash := range hashes {
+		buffer.Write(hash)
+	}
+	for _, entry := range sorted {
+		binary.Write(&buffer, binary.BigEndian, entry.CRC)
+	}
+	var large []uint64
+	for _, entry := range sorted {
+		if entry.Offset < largeOffset {
+			binary.Write(&buffer, binary.BigEndian, uint32(entry.Offset))
+			continue
+		}
+		binary.Write(&buffer, binary.BigEndian, uint32(largeOffset|len(large)))
+		large = append(large, uint64(entry.Offset))
+	}
+	for _, offset := range large {
+		binary.Write(&buffer, binary.BigEndian, offset)
+	}
+	buffer.Write(packChecksum)
+	checksum := sha1.Sum(buffer.Bytes())
+	buffer.Write(checksum[:])
+	return buffer.Bytes(), nil
+}
+
+//Save an index of a pack
+func WriteIndexFile(indexPath string, entries []IndexEntry, packChecksum []byte) error {
+	content, err := EncodeIndex(entries, packChecksum)
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(indexPath), "tmp_idx_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.Write(content)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), indexPath)
+}
+
+//Read an index of a pack, the checksum is verified
+func ReadIndex(indexPath string) (*Index, error) {
+	content, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := DecodeIndex(content)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", indexPath, err.Error()))
+	}
+	return idx, nil
+}
+
+//Parse an index of a pack in the format of version 2
+func DecodeIndex(content []byte) (*Index, error) {
+	headerSize := 8 + 256*4
+	if len(content) < headerSize+2*sha1.Size || string(content[:4]) != indexSignature {
+		return nil, errors.New("Invalid pack index, only version 2 is supported")
+	}
+	if v := binary.BigEndian.Uint32(content[4:8]); v != indexVersion {
+		return nil, errors.New(fmt.Sprintf("Unsupported pack index version %d", v))
+	}
+	body := content[:len(content)-sha1.Size]
+	checksum := sha1.Sum(body)
+	if !bytes.Equal(checksum[:], content[len(body):]) {
+		return nil, errors.New("Invalid pack index, the checksum doesn't match")
+	}
+	idx := &Index{}
+	for i := range idx.fanout {
+		idx.fanout[i] = binary.BigEndian.Uint32(content[8+i*4:])
+	}
+	count := int(idx.fanout[255])
+	hashesStart := headerSize
+	crcStart := hashesStart + count*sha1.Size
+	offsetsStart := crcStart + count*4
+	largeStart := offsetsStart + count*4
+	if largeStart+sha1.Size > len(body) {
+		return nil, errors.New("Invalid pack index, it's truncated")
+	}
+	idx.entries = make([]IndexEntry, count)
+	for i := range idx.entries {
+		offset := int64(binary.BigEndian.Uint32(content[offsetsStart+i*4:]))
+		if offset&largeOffset != 0 {
+			position := largeStart + int(offset&^largeOffset)*8
+			if position+8 > len(body)-sha1.Size {
+				return nil, errors.New("Invalid pack index, a large offset is out of the table")
+			}
+			offset = int64(binary.BigEndian.Uint64(content[position:]))
+		}
+		idx.entries[i] = IndexEntry{
+			Hash:   hex.EncodeToString(content[hashesStart+i*sha1.Size : hashesStart+(i+1)*sha1.Size]),
+			Offset: offset,
+			CRC:    binary.BigEndian.Uint32(content[crcStart+i*4:]),
+		}
+	}
+	idx.PackChecksum = body[len(body)-sha1.Size:]
+	return idx, nil
+}
+
+//All entries sorted by hash
+func (idx *Index) Entries() []IndexEntry {
+	entries := make([]IndexEntry, len(idx.entries))
+	copy(entries, idx.entries)
+	return entries
+}
+
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+//Find an object in the index, the fanout narrows the search to hashes with the same first byte
+func (idx *Index) Find(hash string) (IndexEntry, bool) {
+	if len(hash) != sha1.Size*2 {
+		return IndexEntry{}, false
+	}
+	first, err := hex.DecodeString(hash[:2])
+	if err != nil {
+		return IndexEntry{}, false
+	}
+	low := 0
+	if first[0] > 0 {
+		low = int(idx.fanout[first[0]-1])
+	}
+	high := int(idx.fanout[first[0]])
+	i := low + sort.Search(high-low, func(i int) bool {
+		return idx.entries[low+i].Hash >= hash
+	})
+	if i < high && idx.entries[i].Hash == hash {
+		return idx.entries[i], true
+	}
+	return IndexEntry{}, false
+}
+
+//Hashes of objects that start with given hex prefix
+func (idx *Index) Prefixed(prefix string) []string {
+	i := sort.Search(len(idx.entries), func(i int) bool {
+		return idx.entries[i].Hash >= prefix
+	})
+	var hashes []string
+	for ; i < len(idx.entries) && len(idx.entries[i].Hash) >= len(prefix) &&
+		idx.entries[i].Hash[:len(prefix)] == prefix; i++ {
+		hashes = append(hashes, idx.entries[i].Hash)
+	}
+	return hashes
+}
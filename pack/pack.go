@@ -0,0 +1,89 @@
+package pack
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//type of an entry in a pack, deltas store the difference with another object
+type ObjectType uint8
+
+const (
+	Commit   ObjectType = 1
+	Tree     ObjectType = 2
+	Blob     ObjectType = 3
+	Tag      ObjectType = 4
+	OfsDelta ObjectType = 6 //base is found by a negative offset in the same pack
+	RefDelta ObjectType = 7 //base is found by its hash
+)
+
+const (
+	signature = "PACK"
+	version   = 2
+	//suffixes of a pack and its index
+	PackExt  = ".pack"
+	IndexExt = ".idx"
+)
+
+func (t ObjectType) String() string {
+	switch t {
+	case Commit:
+		return "commit"
+	case Tree:
+		return "tree"
+	case Blob:
+		return "blob"
+	case Tag:
+		return "tag"
+	case OfsDelta:
+		return "ofs-delta"
+	case RefDelta:
+		return "ref-delta"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+//convert a name of an object type to the type stored in a pack
+func TypeOf(name string) (ObjectType, error) {
+	for _, t := range []ObjectType{Commit, Tree, Blob, Tag} {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("%s is not a valid git object type", name))
+}
+
+//whole object that can be stored in a pack
+type Object struct {
+	Hash string //hex encoded SHA-1 of "<type> <size>\x00<data>"
+	Type ObjectType
+	Data []byte
+}
+
+//Create an object and compute its hash like git does
+func NewObject(objType ObjectType, data []byte) Object {
+	return Object{Hash: HashOf(objType, data), Type: objType, Data: data}
+}
+
+//hash of an object with given type and content
+func HashOf(objType ObjectType, data []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s %d\x00", objType, len(data))
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+//Get a path of the index that belongs to a pack
+//Example: "pack-1a2b.pack" -> "pack-1a2b.idx"
+func IndexPath(packPath string) string {
+	return strings.TrimSuffix(packPath, PackExt) + IndexExt
+}
+
+//Get a path of the pack that belongs to an index
+func PackPath(indexPath string) string {
+	return strings.TrimSuffix(indexPath, IndexExt) + PackExt
+}
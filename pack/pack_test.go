@@ -0,0 +1,158 @@
+package pack
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+//versions of a file that differ by a line, they are stored as deltas of each other
+func fakeObjects() []Object {
+	var objects []Object
+	content := ""
+	for i := 0; i < 20; i++ {
+		content += fmt.Sprintf("line number %d of a file that keeps growing\n", i)
+		objects = append(objects, NewObject(Blob, []byte(content)))
+	}
+	objects = append(objects, NewObject(Commit, []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\nmessage\n")))
+	objects = append(objects, NewObject(Tree, []byte{}))
+	return objects
+}
+
+func TestCreateAndOpen(t *testing.T) {
+	for _, options := range []Options{DefaultOptions, {Window: 10, Depth: 50, RefDelta: true}, {Window: 0}} {
+		dir, err := ioutil.TempDir("", "pack")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		objects := fakeObjects()
+		packPath, err := Create(dir, append(objects, objects[0]), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := Open(packPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+		if p.Index().Len() != len(objects) {
+			t.Fatalf("Wrong amount of packed objects, %d expected, got %d", len(objects), p.Index().Len())
+		}
+		for _, object := range objects {
+			read, err := p.Get(object.Hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if read.Type != object.Type || !bytes.Equal(read.Data, object.Data) {
+				t.Fatalf("Wrong content of %s %s", object.Type, object.Hash)
+			}
+		}
+		if p.Has("4b825dc642cb6eb9a060e54bf8d69288fbee4905") {
+			t.Fatal("Pack should not have an object that wasn't written")
+		}
+		verified, err := Verify(packPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deltas := 0
+		for _, object := range verified {
+			if object.Depth > 0 {
+				deltas++
+				if object.Depth > options.Depth || object.Base == "" {
+					t.Fatalf("Wrong chain of %s: depth %d, base '%s'", object.Hash, object.Depth, object.Base)
+				}
+			}
+		}
+		if options.Window > 0 && deltas == 0 {
+			t.Fatal("Similar blobs should be stored as deltas")
+		}
+		if options.Window == 0 && deltas != 0 {
+			t.Fatal("Deltas should not be used without a window")
+		}
+	}
+}
+
+func TestIndexPack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, options := range []Options{DefaultOptions, {Window: 10, Depth: 50, RefDelta: true}} {
+		packPath, err := Create(dir, fakeObjects(), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written, err := ioutil.ReadFile(IndexPath(packPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(IndexPath(packPath))
+		idx, err := IndexPack(packPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rebuilt, err := ioutil.ReadFile(IndexPath(packPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written, rebuilt) || idx.Len() != len(fakeObjects()) {
+			t.Fatal("Index built from a pack differs from the written one")
+		}
+	}
+}
+
+func TestVerify_corrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	packPath, err := Create(dir, fakeObjects(), DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content[20] ^= 0xFF
+	err = ioutil.WriteFile(packPath, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(packPath); err == nil {
+		t.Fatal("Corrupted pack should not be verified")
+	}
+	if _, err = IndexPack(packPath); err == nil {
+		t.Fatal("Corrupted pack should not be indexed")
+	}
+}
+
+func TestEncodeIndex_largeOffsets(t *testing.T) {
+	entries := []IndexEntry{
+		{Hash: "ff00000000000000000000000000000000000000", Offset: 12, CRC: 1},
+		{Hash: "00ff000000000000000000000000000000000000", Offset: 1 << 33, CRC: 2},
+		{Hash: "0100000000000000000000000000000000000000", Offset: 1<<31 + 5, CRC: 3},
+	}
+	content, err := EncodeIndex(entries, make([]byte, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := DecodeIndex(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range entries {
+		entry, ok := idx.Find(expected.Hash)
+		if !ok || entry != expected {
+			t.Fatalf("Wrong entry %+v, %+v expected", entry, expected)
+		}
+	}
+	if prefixed := idx.Prefixed("0"); len(prefixed) != 2 {
+		t.Fatalf("Two hashes start with 0, got %v", prefixed)
+	}
+}
@@ -0,0 +1,278 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+const (
+	//a longer chain of deltas means that a pack refers to itself
+	maxChain = 10000
+	//resolved objects that are kept to rebuild chains of deltas
+	maxCached = 256
+)
+
+//pack opened for reading, objects are found by the index of the pack
+type Pack struct {
+	path  string
+	file  *os.File
+	size  int64
+	index *Index
+	//offsets of resolved objects while an index is being built
+	offsets map[string]int64
+	cache   map[int64]Object
+}
+
+//entry as it's stored in a pack, deltas are not applied
+type rawEntry struct {
+	objType    ObjectType
+	data       []byte //content of an object or a delta
+	baseOffset int64  //offset of the base of OFS_DELTA
+	baseHash   string //hash of the base of REF_DELTA
+	length     int64  //amount of bytes the entry takes in the pack
+}
+
+//Open a pack and read its index
+func Open(packPath string) (*Pack, error) {
+	idx, err := ReadIndex(IndexPath(packPath))
+	if err != nil {
+		return nil, err
+	}
+	p, err := openPack(packPath)
+	if err != nil {
+		return nil, err
+	}
+	p.index = idx
+	return p, nil
+}
+
+//open a pack file and check its header
+func openPack(packPath string) (*Pack, error) {
+	file, err := os.Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	header := make([]byte, 12)
+	_, err = file.ReadAt(header, 0)
+	if err != nil || string(header[:4]) != signature || info.Size() < 12+sha1.Size {
+		file.Close()
+		return nil, errors.New(fmt.Sprintf("%s is not a pack file", packPath))
+	}
+	if v := binary.BigEndian.Uint32(header[4:8]); v != version {
+		file.Close()
+		return nil, errors.New(fmt.Sprintf("Unsupported pack version %d in %s", v, packPath))
+	}
+	return &Pack{
+		path:  packPath,
+		file:  file,
+		size:  info.Size(),
+		cache: make(map[int64]Object),
+	}, nil
+}
+
+func (p *Pack) Close() error {
+	return p.file.Close()
+}
+
+func (p *Pack) Path() string {
+	return p.path
+}
+
+func (p *Pack) Index() *Index {
+	return p.index
+}
+
+//Check if the pack contains an object
+func (p *Pack) Has(hash string) bool {
+	_, ok := p.offset(hash)
+	return ok
+}
+
+//Read an object from the pack, deltas are resolved
+func (p *Pack) Get(hash string) (Object, error) {
+	offset, ok := p.offset(hash)
+	if !ok {
+		return Object{}, errors.New(fmt.Sprintf("Object with hash %s is not in %s", hash, p.path))
+	}
+	object, err := p.resolve(offset, 0)
+	if err != nil {
+		return Object{}, err
+	}
+	object.Hash = hash
+	return object, nil
+}
+
+func (p *Pack) offset(hash string) (int64, bool) {
+	if p.index != nil {
+		entry, ok := p.index.Find(hash)
+		return entry.Offset, ok
+	}
+	offset, ok := p.offsets[hash]
+	return offset, ok
+}
+
+//read an object at given offset and apply deltas of its chain
+func (p *Pack) resolve(offset int64, chain int) (Object, error) {
+	if object, ok := p.cache[offset]; ok {
+		return object, nil
+	}
+	if chain > maxChain {
+		return Object{}, errors.New(fmt.Sprintf("Chain of deltas at offset %d is too long", offset))
+	}
+	raw, err := p.readRaw(offset)
+	if err != nil {
+		return Object{}, err
+	}
+	var object Object
+	switch raw.objType {
+	case Commit, Tree, Blob, Tag:
+		object = Object{Type: raw.objType, Data: raw.data}
+	case OfsDelta, RefDelta:
+		baseOffset := offset - raw.baseOffset
+		if raw.objType == RefDelta {
+			var ok bool
+			baseOffset, ok = p.offset(raw.baseHash)
+			if !ok {
+				return Object{}, errors.New(
+					fmt.Sprintf("Base %s of delta at offset %d is not in the pack", raw.baseHash, offset),
+				)
+			}
+		}
+		base, err := p.resolve(baseOffset, chain+1)
+		if err != nil {
+			return Object{}, err
+		}
+		data, err := ApplyDelta(base.Data, raw.data)
+		if err != nil {
+			return Object{}, errors.New(fmt.Sprintf("Delta at offset %d: %s", offset, err.Error()))
+		}
+		object = Object{Type: base.Type, Data: data}
+	default:
+		return Object{}, errors.New(
+			fmt.Sprintf("Invalid object type %d at offset %d", raw.objType, offset),
+		)
+	}
+	if len(p.cache) >= maxCached {
+		p.cache = make(map[int64]Object)
+	}
+	p.cache[offset] = object
+	return object, nil
+}
+
+//parse an entry of a pack at given offset
+func (p *Pack) readRaw(offset int64) (*rawEntry, error) {
+	end := p.size - sha1.Size
+	if offset < 12 || offset >= end {
+		return nil, errors.New(fmt.Sprintf("Offset %d is out of the pack %s", offset, p.path))
+	}
+	reader := &countingReader{reader: bufio.NewReader(io.NewSectionReader(p.file, offset, end-offset))}
+	truncated := errors.New(fmt.Sprintf("Entry at offset %d is truncated", offset))
+	b, err := reader.ReadByte()
+	if err != nil {
+		return nil, truncated
+	}
+	raw := &rawEntry{objType: ObjectType(b >> 4 & 0x7)}
+	size := uint64(b & 0x0f)
+	for shift := uint(4); b&0x80 != 0; shift += 7 {
+		if b, err = reader.ReadByte(); err != nil {
+			return nil, truncated
+		}
+		size |= uint64(b&0x7f) << shift
+	}
+	switch raw.objType {
+	case OfsDelta:
+		if b, err = reader.ReadByte(); err != nil {
+			return nil, truncated
+		}
+		raw.baseOffset = int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = reader.ReadByte(); err != nil {
+				return nil, truncated
+			}
+			raw.baseOffset = (raw.baseOffset+1)<<7 | int64(b&0x7f)
+		}
+		if raw.baseOffset <= 0 || raw.baseOffset > offset {
+			return nil, errors.New(fmt.Sprintf("Invalid base offset of delta at offset %d", offset))
+		}
+	case RefDelta:
+		hash := make([]byte, sha1.Size)
+		if _, err = io.ReadFull(reader, hash); err != nil {
+			return nil, truncated
+		}
+		raw.baseHash = hex.EncodeToString(hash)
+	}
+	inflater, err := zlib.NewReader(reader)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Entry at offset %d: %s", offset, err.Error()))
+	}
+	var data bytes.Buffer
+	_, err = io.Copy(&data, inflater)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Entry at offset %d: %s", offset, err.Error()))
+	}
+	if uint64(data.Len()) != size {
+		return nil, errors.New(
+			fmt.Sprintf("Entry at offset %d has %d bytes, %d expected", offset, data.Len(), size),
+		)
+	}
+	raw.data = data.Bytes()
+	raw.length = reader.count
+	return raw, nil
+}
+
+//checksum of the bytes that an entry takes in the pack
+func (p *Pack) crc(offset int64, length int64) (uint32, error) {
+	checksum := crc32.NewIEEE()
+	_, err := io.Copy(checksum, io.NewSectionReader(p.file, offset, length))
+	return checksum.Sum32(), err
+}
+
+//SHA-1 of the pack content without the trailing checksum
+func (p *Pack) checksum() ([]byte, []byte, error) {
+	hash := sha1.New()
+	_, err := io.Copy(hash, io.NewSectionReader(p.file, 0, p.size-sha1.Size))
+	if err != nil {
+		return nil, nil, err
+	}
+	trailer := make([]byte, sha1.Size)
+	_, err = p.file.ReadAt(trailer, p.size-sha1.Size)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hash.Sum(nil), trailer, nil
+}
+
+//reader that remembers the amount of read bytes,
+//it implements io.ByteReader so zlib doesn't read ahead of a compressed entry
+type countingReader struct {
+	reader *bufio.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.count++
+	}
+	return b, err
+}
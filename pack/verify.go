@@ -0,0 +1,177 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+//object of a pack as verify-pack reports it
+type VerifiedObject struct {
+	Hash       string
+	Type       ObjectType //type of the resolved object
+	Size       int        //size of the stored data, a delta for deltified objects
+	PackedSize int64      //amount of compressed bytes in the pack
+	Offset     int64
+	Depth      int    //length of the chain of deltas, 0 for whole objects
+	Base       string //hash of the base of a delta
+}
+
+//Build an index for a pack that doesn't have one
+//Every entry is parsed, deltas are resolved to compute hashes of objects
+//and the checksum of the pack is verified.
+//Returns the index, it's saved next to the pack
+func IndexPack(packPath string) (*Index, error) {
+	p, err := openPack(packPath)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+	actual, trailer, err := p.checksum()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(actual, trailer) {
+		return nil, errors.New(fmt.Sprintf("Checksum of %s doesn't match", packPath))
+	}
+	header := make([]byte, 12)
+	_, err = p.file.ReadAt(header, 0)
+	if err != nil {
+		return nil, err
+	}
+	count := binary.BigEndian.Uint32(header[8:])
+	p.offsets = make(map[string]int64)
+	var deltas []IndexEntry
+	var entries []IndexEntry
+	offset := int64(12)
+	for i := uint32(0); i < count; i++ {
+		raw, err := p.readRaw(offset)
+		if err != nil {
+			return nil, err
+		}
+		crc, err := p.crc(offset, raw.length)
+		if err != nil {
+			return nil, err
+		}
+		entry := IndexEntry{Offset: offset, CRC: crc}
+		if raw.objType == OfsDelta || raw.objType == RefDelta {
+			deltas = append(deltas, entry)
+		} else {
+			entry.Hash = HashOf(raw.objType, raw.data)
+			p.offsets[entry.Hash] = offset
+			entries = append(entries, entry)
+		}
+		offset += raw.length
+	}
+	if offset != p.size-int64(len(trailer)) {
+		return nil, errors.New(
+			fmt.Sprintf("%s has %d bytes of garbage after the last object", packPath, p.size-int64(len(trailer))-offset),
+		)
+	}
+	//a REF_DELTA may refer to a delta that is later in the pack, so deltas are resolved
+	//in rounds until every base is known
+	for len(deltas) > 0 {
+		var unresolved []IndexEntry
+		for _, entry := range deltas {
+			object, err := p.resolve(entry.Offset, 0)
+			if err != nil {
+				unresolved = append(unresolved, entry)
+				continue
+			}
+			entry.Hash = HashOf(object.Type, object.Data)
+			p.offsets[entry.Hash] = entry.Offset
+			entries = append(entries, entry)
+		}
+		if len(unresolved) == len(deltas) {
+			_, err := p.resolve(unresolved[0].Offset, 0)
+			return nil, err
+		}
+		deltas = unresolved
+	}
+	err = WriteIndexFile(IndexPath(packPath), entries, trailer)
+	if err != nil {
+		return nil, err
+	}
+	return ReadIndex(IndexPath(packPath))
+}
+
+//Check a pack and its index
+//The checksums of both files must match, every object is read,
+//its deltas are applied and the result must have the hash from the index
+func Verify(packPath string) ([]VerifiedObject, error) {
+	p, err := Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+	actual, trailer, err := p.checksum()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(actual, trailer) {
+		return nil, errors.New(fmt.Sprintf("Checksum of %s doesn't match", packPath))
+	}
+	if !bytes.Equal(trailer, p.index.PackChecksum) {
+		return nil, errors.New(
+			fmt.Sprintf("%s doesn't belong to %s", IndexPath(packPath), packPath),
+		)
+	}
+	byOffset := make(map[int64]string)
+	for _, entry := range p.index.entries {
+		byOffset[entry.Offset] = entry.Hash
+	}
+	var verified []VerifiedObject
+	for _, entry := range p.index.entries {
+		raw, err := p.readRaw(entry.Offset)
+		if err != nil {
+			return nil, err
+		}
+		crc, err := p.crc(entry.Offset, raw.length)
+		if err != nil {
+			return nil, err
+		}
+		if crc != entry.CRC {
+			return nil, errors.New(fmt.Sprintf("CRC of %s doesn't match the index", entry.Hash))
+		}
+		object, err := p.resolve(entry.Offset, 0)
+		if err != nil {
+			return nil, err
+		}
+		if hash := HashOf(object.Type, object.Data); hash != entry.Hash {
+			return nil, errors.New(
+				fmt.Sprintf("Object at offset %d has hash %s, %s expected", entry.Offset, hash, entry.Hash),
+			)
+		}
+		result := VerifiedObject{
+			Hash:       entry.Hash,
+			Type:       object.Type,
+			Size:       len(raw.data),
+			PackedSize: raw.length,
+			Offset:     entry.Offset,
+		}
+		//resolve succeeded so the chain ends with a whole object
+		current := entry.Offset
+		for raw.objType == OfsDelta || raw.objType == RefDelta {
+			baseOffset := current - raw.baseOffset
+			if raw.objType == RefDelta {
+				baseOffset, _ = p.offset(raw.baseHash)
+			}
+			if result.Depth == 0 {
+				result.Base = byOffset[baseOffset]
+			}
+			result.Depth++
+			current = baseOffset
+			if raw, err = p.readRaw(current); err != nil {
+				return nil, err
+			}
+		}
+		verified = append(verified, result)
+	}
+	//objects are reported in the order of the pack like git does
+	sort.Slice(verified, func(i, j int) bool {
+		return verified[i].Offset < verified[j].Offset
+	})
+	return verified, nil
+}
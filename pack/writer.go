@@ -0,0 +1,194 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+//how hard pack-objects looks for deltas
+type Options struct {
+	Window   int  //amount of previous objects that are tried as a base
+	Depth    int  //the longest chain of deltas
+	RefDelta bool //refer to bases by hash instead of offset
+}
+
+//options used by git by default
+var DefaultOptions = Options{Window: 10, Depth: 50}
+
+//object that is written into a pack
+type packed struct {
+	object Object
+	offset int64
+	depth  int
+}
+
+//Write objects in the pack format of git
+//Objects are sorted by type and size, each one is compared with the previous
+//objects in a window of the same type and stored as a delta of the most similar one
+//Returns entries of written objects for the index and the checksum of the pack
+func Write(writer io.Writer, objects []Object, options Options) ([]IndexEntry, []byte, error) {
+	var sorted []Object
+	unique := make(map[string]bool)
+	for _, object := range objects {
+		if !unique[object.Hash] {
+			unique[object.Hash] = true
+			sorted = append(sorted, object)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Type != sorted[j].Type {
+			return sorted[i].Type < sorted[j].Type
+		}
+		return len(sorted[i].Data) > len(sorted[j].Data)
+	})
+	checksum := sha1.New()
+	out := &countingWriter{writer: io.MultiWriter(writer, checksum)}
+	var header bytes.Buffer
+	header.WriteString(signature)
+	binary.Write(&header, binary.BigEndian, uint32(version))
+	binary.Write(&header, binary.BigEndian, uint32(len(sorted)))
+	if _, err := out.Write(header.Bytes()); err != nil {
+		return nil, nil, err
+	}
+	var window []*packed
+	var entries []IndexEntry
+	for _, object := range sorted {
+		current := &packed{object: object, offset: out.count}
+		base, delta := bestDelta(window, object, options)
+		var entry bytes.Buffer
+		switch {
+		case base == nil:
+			writeEntryHeader(&entry, object.Type, len(object.Data))
+			delta = object.Data
+		case options.RefDelta:
+			current.depth = base.depth + 1
+			writeEntryHeader(&entry, RefDelta, len(delta))
+			hash, _ := hex.DecodeString(base.object.Hash)
+			entry.Write(hash)
+		default:
+			current.depth = base.depth + 1
+			writeEntryHeader(&entry, OfsDelta, len(delta))
+			writeOffset(&entry, current.offset-base.offset)
+		}
+		compressed := zlib.NewWriter(&entry)
+		compressed.Write(delta)
+		compressed.Close()
+		if _, err := out.Write(entry.Bytes()); err != nil {
+			return nil, nil, err
+		}
+		entries = append(
+			entries,
+			IndexEntry{Hash: object.Hash, Offset: current.offset, CRC: crc32.ChecksumIEEE(entry.Bytes())},
+		)
+		if options.Window > 0 {
+			window = append(window, current)
+			if len(window) > options.Window {
+				window = window[1:]
+			}
+		}
+	}
+	sum := checksum.Sum(nil)
+	if _, err := writer.Write(sum); err != nil {
+		return nil, nil, err
+	}
+	return entries, sum, nil
+}
+
+//Write a pack and its index into given directory
+//Files are named "pack-<checksum>.pack" and "pack-<checksum>.idx" like in git,
+//the pack is renamed into place only when it's complete.
+//Returns the path of the written pack
+func Create(dir string, objects []Object, options Options) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	temp, err := ioutil.TempFile(dir, "tmp_pack_")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+	entries, checksum, err := Write(temp, objects, options)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	packPath := filepath.Join(dir, "pack-"+hex.EncodeToString(checksum)+PackExt)
+	err = WriteIndexFile(IndexPath(packPath), entries, checksum)
+	if err != nil {
+		return "", err
+	}
+	return packPath, os.Rename(temp.Name(), packPath)
+}
+
+//find the object of a window that gives the smallest delta,
+//a delta is only used if it's less than half of the object
+func bestDelta(window []*packed, object Object, options Options) (*packed, []byte) {
+	var base *packed
+	var best []byte
+	for i := len(window) - 1; i >= 0; i-- {
+		candidate := window[i]
+		if candidate.object.Type != object.Type || candidate.depth >= options.Depth {
+			continue
+		}
+		delta := CreateDelta(candidate.object.Data, object.Data)
+		if len(delta) >= len(object.Data)/2 {
+			continue
+		}
+		//shorter chains are faster to read so a shallower base wins a tie
+		if best == nil || len(delta) < len(best) ||
+			(len(delta) == len(best) && candidate.depth < base.depth) {
+			base, best = candidate, delta
+		}
+	}
+	return base, best
+}
+
+//type and size of an entry, the size takes 4 bits of the first byte and 7 bits of the next ones
+func writeEntryHeader(buffer *bytes.Buffer, objType ObjectType, size int) {
+	b := byte(objType)<<4 | byte(size&0x0f)
+	size >>= 4
+	for size > 0 {
+		buffer.WriteByte(b | 0x80)
+		b = byte(size & 0x7f)
+		size >>= 7
+	}
+	buffer.WriteByte(b)
+}
+
+//distance to the base of an OFS_DELTA, big endian base 128
+//where each continuation adds one so there is only one encoding of a number
+func writeOffset(buffer *bytes.Buffer, offset int64) {
+	encoded := []byte{byte(offset & 0x7f)}
+	for offset >>= 7; offset > 0; offset >>= 7 {
+		offset--
+		encoded = append([]byte{byte(offset&0x7f) | 0x80}, encoded...)
+	}
+	buffer.Write(encoded)
+}
+
+//writer that remembers the amount of written bytes, it's the offset of the next entry
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
@@ -1,23 +1,38 @@
 package repository
 
 import (
+	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
 )
 
 const (
 	Objects     = "/objects/"
 	Refs        = "/refs"
 	Heads       = "/heads/"
+	Tags        = "/tags/"
 	Head        = "/HEAD"
 	Config      = "/config.json"
-	Description = "Description"
+	Description = "/description"
 	Index       = "/index"
+	MergeHead   = "/MERGE_HEAD"
+	Logs        = "/logs"
+	Exclude     = "/info/exclude"
+	//name of the repository directory inside of a working tree
+	RepoDir = ".dzhigit"
+	//branch used by a repository without commits
+	DefaultBranch = "master"
 )
 
 func DefaultPath() string {
 	path, _ := os.Getwd()
-	return path + "/.dzhigit"
+	return path + "/" + RepoDir
+}
+
+//the directory that contains the dzhigit repository
+func WorkTreePath(path string) string {
+	return filepath.Dir(path)
 }
 
 func PathToBranch(branchName string) string {
@@ -33,6 +48,10 @@ func HeadsPath(path string) string {
 	return path + Refs + Heads
 }
 
+func TagsPath(path string) string {
+	return path + Refs + Tags
+}
+
 func ConfigPath(path string) string {
 	return path + Config
 }
@@ -41,6 +60,22 @@ func IndexPath(path string) string {
 	return path + Index
 }
 
+//file with a commit that is being merged into the current branch
+func MergeHeadPath(path string) string {
+	return path + MergeHead
+}
+
+//log of movements of given ref
+//Example: "HEAD" -> ".dzhigit/logs/HEAD", "refs/heads/master" -> ".dzhigit/logs/refs/heads/master"
+func LogPath(path string, ref string) string {
+	return path + Logs + "/" + ref
+}
+
+//ignore patterns of a repository that are not shared with other clones
+func ExcludePath(path string) string {
+	return path + Exclude
+}
+
 func ObjPath(path string) string {
 	return path + Objects
 }
@@ -63,8 +98,28 @@ func Init(path string, userJson []byte) error {
 	}
 }
 
+//Create a repository without a working tree, e.g. a shared central repository
+//Files of the repository are created right in given directory which may already exist
+//and the config is marked as bare, so commands that need a working tree refuse to run
+func InitBare(path string, userJson []byte) error {
+	if isRepository(path) {
+		return errors.New("dzhigit repository already exists")
+	}
+	var config map[string]interface{}
+	err := json.Unmarshal(userJson, &config)
+	if err != nil {
+		return err
+	}
+	config[bareKey] = true
+	content, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return initRepo(path, content)
+}
+
 func initRepo(path string, userJson []byte) error {
-	err := os.Mkdir(path, 0755)
+	err := os.MkdirAll(path, 0755)
 	if err != nil {
 		return err
 	}
@@ -76,31 +131,35 @@ func initRepo(path string, userJson []byte) error {
 	//Create Refs dir
 	err = os.Mkdir(path+Refs, 0755)
 	if err != nil {
-		return nil
+		return err
 	}
 	err = os.Mkdir(path+Refs+Heads, 0755)
 	if err != nil {
-		return nil
+		return err
+	}
+	err = os.Mkdir(path+Refs+Tags, 0755)
+	if err != nil {
+		return err
 	}
 	//Create Config
 	config, err := os.Create(path + Config)
 	if err != nil {
-		return nil
+		return err
 	}
 	defer config.Close()
 	_, err = config.Write(userJson)
 	if err != nil {
-		return nil
+		return err
 	}
 	//Create Description
 	_, err = os.Create(path + Description)
 	if err != nil {
-		return nil
+		return err
 	}
 	//Create Head
 	_, err = os.Create(path + Head)
 	if err != nil {
-		return nil
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//suffix of a file that holds a new content of a locked file
+const LockExt = ".lock"
+
+//Lock of a file
+//The new content is written into "<file>.lock" which is renamed over the file,
+//so readers see either the old or the new content and never a truncated file.
+//Only one process can hold the lock, the second one fails instead of waiting
+type Lock struct {
+	path string
+	file *os.File
+}
+
+//Take a lock of a file, the directory of the file is created if it's missing
+func LockFile(path string) (*Lock, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path+LockExt, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, errors.New(
+				fmt.Sprintf(
+					"Unable to create '%s': File exists.\nAnother dzhigit process seems to be running in this repository",
+					path+LockExt,
+				),
+			)
+		}
+		return nil, err
+	}
+	return &Lock{path: path, file: file}, nil
+}
+
+//Replace the locked file with given content and release the lock
+func (l *Lock) Commit(content []byte, perm os.FileMode) error {
+	err := writeSynced(l.file, content, perm)
+	if err != nil {
+		l.Rollback()
+		return err
+	}
+	err = os.Rename(l.path+LockExt, l.path)
+	if err != nil {
+		os.Remove(l.path + LockExt)
+	}
+	return err
+}
+
+//Release the lock without changing the file
+func (l *Lock) Rollback() {
+	l.file.Close()
+	os.Remove(l.path + LockExt)
+}
+
+//Write a file under its lock
+func WriteLocked(path string, content []byte, perm os.FileMode) error {
+	lock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	return lock.Commit(content, perm)
+}
+
+//Write a file under its lock only if it still has the old content
+//Surrounding whitespace is ignored, an empty old content means the file must not exist or be empty
+func CompareAndSwap(path string, old []byte, content []byte, perm os.FileMode) error {
+	lock, err := LockFile(path)
+	if err != nil {
+		return err
+	}
+	current, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		lock.Rollback()
+		return err
+	}
+	if !bytes.Equal(bytes.TrimSpace(current), bytes.TrimSpace(old)) {
+		lock.Rollback()
+		return errors.New(
+			fmt.Sprintf("%s was changed by another process, expected '%s', got '%s'",
+				path,
+				bytes.TrimSpace(old),
+				bytes.TrimSpace(current),
+			),
+		)
+	}
+	return lock.Commit(content, perm)
+}
+
+//Write a file into a temporary file in the same directory and rename it into place
+//An existing file is kept, it's used for content addressed files that can't change
+func WriteOnce(path string, content []byte, perm os.FileMode) error {
+	if Exists(path) {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), "tmp_obj_")
+	if err != nil {
+		return err
+	}
+	err = writeSynced(file, content, perm)
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+//write the whole content, flush it to the disk and close the file
+func writeSynced(file *os.File, content []byte, perm os.FileMode) error {
+	_, err := file.Write(content)
+	if err == nil {
+		err = file.Chmod(perm)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+//Check if a file is a lock of another file
+func IsLockFile(path string) bool {
+	return filepath.Ext(path) == LockExt
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "refs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ref := dir + "/heads/feature/login"
+	err = CompareAndSwap(ref, nil, []byte("first"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CompareAndSwap(ref, nil, []byte("second"), 0644)
+	if err == nil {
+		t.Fatal("A ref that already exists should not be created again")
+	}
+	err = CompareAndSwap(ref, []byte("first\n"), []byte("second"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := LockFile(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteLocked(ref, []byte("third"), 0644)
+	if err == nil {
+		t.Fatal("A locked ref should not be written")
+	}
+	lock.Rollback()
+	content, err := ioutil.ReadFile(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "second" || Exists(ref+LockExt) {
+		t.Fatalf("Wrong content of the ref '%s' or the lock was kept", content)
+	}
+}
+
+func TestSave_existingObject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "objects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	objPath := dir + Objects
+	formatter := DefaultGitFileFormatter{}
+	blob, err := formatter.Serialize([]byte("blob"), BLOB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = formatter.Save(blob, objPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the content is not written again, so a corrupt object stays corrupt
+	blob.Content = []byte("garbage")
+	err = formatter.Save(blob, objPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deser, err := NewLooseStore(objPath).Get(blob.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deser.Content != "blob" {
+		t.Fatalf("Existing object was rewritten, got '%s'", deser.Content)
+	}
+	files, err := ioutil.ReadDir(objPath + blob.Hash.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Temporary files were left, got %d files", len(files))
+	}
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+//Objects saved as separate zlib compressed files
+//Example: ".dzhigit/objects/3b/0af1dd47d543b2166440b83bbf0ed0235173d8"
+type LooseStore struct {
+	objPath   string
+	formatter DefaultGitFileFormatter
+}
+
+//Example: ".dzhigit/objects/"
+func NewLooseStore(objPath string) *LooseStore {
+	return &LooseStore{objPath: objPath}
+}
+
+//directory of objects
+func (s *LooseStore) Dir() string {
+	return s.objPath
+}
+
+//path of the file of an object
+func (s *LooseStore) Path(hash Hash) string {
+	return hash.Path(s.objPath)
+}
+
+func (s *LooseStore) Has(hash Hash) bool {
+	return len(hash) > 2 && Exists(s.Path(hash))
+}
+
+func (s *LooseStore) Get(hash Hash) (*DeserializedGitObject, error) {
+	if !s.Has(hash) {
+		return nil, missingObjectError(hash)
+	}
+	content, err := ioutil.ReadFile(s.Path(hash))
+	if err != nil {
+		return nil, err
+	}
+	return s.formatter.Deserialize(content)
+}
+
+//The object is written into a temporary file which is renamed into place,
+//an object that already exists is not written again because its content can't differ
+func (s *LooseStore) Put(objType GitObjectType, content []byte) (Hash, error) {
+	ser, err := s.formatter.Serialize(content, objType)
+	if err != nil {
+		return "", err
+	}
+	return ser.Hash, s.formatter.Save(ser, s.objPath)
+}
+
+//objects are visited in the order of hashes
+func (s *LooseStore) Iterate(fn func(hash Hash) error) error {
+	dirs, err := ioutil.ReadDir(s.objPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 || !isHex(dir.Name()) {
+			continue
+		}
+		files, err := ioutil.ReadDir(s.objPath + dir.Name())
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			hash, err := NewHash(dir.Name() + file.Name())
+			if err != nil || !isHex(file.Name()) {
+				//temporary files of objects that are being written
+				continue
+			}
+			err = fn(hash)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *LooseStore) Stat(hash Hash) (*ObjectInfo, error) {
+	info, err := os.Stat(s.Path(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, missingObjectError(hash)
+		}
+		return nil, err
+	}
+	deser, err := s.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{
+		Type:    deser.ObjType,
+		Size:    int64(len(deser.Content)),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+//Delete the file of an object, the directory is removed when it becomes empty
+func (s *LooseStore) Remove(hash Hash) error {
+	err := os.Remove(s.Path(hash))
+	if err != nil {
+		return err
+	}
+	os.Remove(s.objPath + hash.Dir())
+	return nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"sort"
+	"time"
+)
+
+//Objects kept in memory, they are lost when the process exits
+type MemoryStore struct {
+	objects map[Hash]memoryObject
+}
+
+type memoryObject struct {
+	objType GitObjectType
+	content []byte
+	modTime time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[Hash]memoryObject)}
+}
+
+func (s *MemoryStore) Has(hash Hash) bool {
+	_, ok := s.objects[hash]
+	return ok
+}
+
+func (s *MemoryStore) Get(hash Hash) (*DeserializedGitObject, error) {
+	object, ok := s.objects[hash]
+	if !ok {
+		return nil, missingObjectError(hash)
+	}
+	return &DeserializedGitObject{
+		ObjType: object.objType,
+		Content: string(object.content),
+	}, nil
+}
+
+func (s *MemoryStore) Put(objType GitObjectType, content []byte) (Hash, error) {
+	hash, err := GenerateHash(append(header(content, objType), content...))
+	if err != nil {
+		return "", err
+	}
+	if _, ok := s.objects[Hash(hash)]; !ok {
+		s.objects[Hash(hash)] = memoryObject{
+			objType: objType,
+			content: append([]byte{}, content...),
+			modTime: time.Now(),
+		}
+	}
+	return Hash(hash), nil
+}
+
+func (s *MemoryStore) Iterate(fn func(hash Hash) error) error {
+	hashes := make([]Hash, 0, len(s.objects))
+	for hash := range s.objects {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i] < hashes[j]
+	})
+	for _, hash := range hashes {
+		err := fn(hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Stat(hash Hash) (*ObjectInfo, error) {
+	object, ok := s.objects[hash]
+	if !ok {
+		return nil, missingObjectError(hash)
+	}
+	return &ObjectInfo{
+		Type:    object.objType,
+		Size:    int64(len(object.content)),
+		ModTime: object.modTime,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+type Mode string
+
+const (
+	FILE       Mode = "100644"
+	EXECUTABLE      = "100755"
+	SYMLINK         = "120000"
+	DIR             = "40000" //git writes modes of subtrees without a leading zero
+)
+
+func AsMode(mode string) (Mode, error) {
+	switch mode {
+	case "100644":
+		return FILE, nil
+	case "100755":
+		return EXECUTABLE, nil
+	case "120000":
+		return SYMLINK, nil
+	case "40000", "040000":
+		return DIR, nil
+	default:
+		return "", errors.New(fmt.Sprintf("Invalid file mode '%s'", mode))
+	}
+}
+
+//Mode of a file in the working tree, executable if any execute bit is set
+//info has to be taken with os.Lstat to recognize symbolic links
+func FileMode(info os.FileInfo) Mode {
+	if info.Mode()&os.ModeSymlink != 0 {
+		return SYMLINK
+	}
+	if info.Mode()&0111 != 0 {
+		return EXECUTABLE
+	}
+	return FILE
+}
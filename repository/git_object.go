@@ -1,7 +1,6 @@
 package repository
 
 import (
-	"bufio"
 	"bytes"
 	"compress/zlib"
 	"crypto/sha1"
@@ -9,7 +8,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
 	"strconv"
 	"strings"
 )
@@ -20,31 +18,16 @@ const (
 	BLOB   GitObjectType = "blob"
 	TREE                 = "tree"
 	COMMIT               = "commit"
+	TAG                  = "tag"
 )
 
-type (
-	ObjectReader func(
-		path string,
-		formatter GitFileFormatter,
-	) (*DeserializedGitObject, error)
-
-	FileReader func(path string) ([]byte, error)
-)
+type FileReader func(path string) ([]byte, error)
 
 var (
 	//reader to read a raw content from given path
 	Reader FileReader = func(path string) ([]byte, error) {
 		return ioutil.ReadFile(path)
 	}
-
-	//reader to read raw content as git obj
-	ObjReader ObjectReader = func(path string, formatter GitFileFormatter) (*DeserializedGitObject, error) {
-		content, err := ioutil.ReadFile(path)
-		if err != nil {
-			return nil, err
-		}
-		return formatter.Deserialize(content)
-	}
 )
 
 type GitFileFormatter interface {
@@ -53,10 +36,6 @@ type GitFileFormatter interface {
 	Save(serialized *SerializedGitObject, path string) error
 }
 
-type TreeAlreadyExistError struct {
-	message string
-}
-
 type DefaultGitFileFormatter struct {
 }
 
@@ -72,6 +51,9 @@ type SerializedGitObject struct {
 
 type Hash string
 
+//the shortest abbreviated hash that can be expanded
+const MinHashPrefix = 4
+
 func NewHash(hash string) (Hash, error) {
 	if len(hash) != sha1.Size*2 {
 		return "",
@@ -99,16 +81,6 @@ func (h Hash) FileName() string {
 	return string(h)[2:]
 }
 
-func (err *TreeAlreadyExistError) Error() string {
-	return err.message
-}
-
-func NewTreeAlreadyExistError(message string) *TreeAlreadyExistError {
-	return &TreeAlreadyExistError{
-		message: message,
-	}
-}
-
 func (obj *DefaultGitFileFormatter) Deserialize(data []byte) (*DeserializedGitObject, error) {
 	unzipped, err := unzipped(data)
 	unzippedContent := string(unzipped)
@@ -135,53 +107,34 @@ func (obj *DefaultGitFileFormatter) Serialize(data []byte, objType GitObjectType
 	}, nil
 }
 
+//Save an object into the object repository
+//The object is written into a temporary file which is renamed into place,
+//an object that already exists is not written again because its content can't differ
 func (obj *DefaultGitFileFormatter) Save(serialized *SerializedGitObject, objPath string) error {
-	fullPath := objPath + serialized.Hash.Dir()
-	//dir may already exist
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		os.Mkdir(fullPath, 0755)
-	}
-	file, err := os.Create(fullPath + "/" + serialized.Hash.FileName())
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	writer := bufio.NewWriter(file)
-	_, err = writer.Write(serialized.Content)
-	if err != nil {
-		return err
-	}
-	return writer.Flush()
+	return WriteOnce(serialized.Hash.Path(objPath), serialized.Content, 0444)
 }
 
-//Get type of a object by given hash
-func TypeByHash(
-	objPath string,
-	hash Hash,
-	reader FileReader,
-	fileFormatter GitFileFormatter,
-) (GitObjectType, error) {
-	if !Exists(objPath + hash.Dir() + "/" + hash.FileName()) {
-		return "", errors.New(fmt.Sprintf("Object with hash %s doesn't exist", hash))
-	}
-	data, err := reader(objPath + hash.Dir() + "/" + hash.FileName())
-	if err != nil {
-		return "", err
-	}
-	deser, err := fileFormatter.Deserialize(data)
-	if err != nil {
-		return "", err
+//check that a string consists of lower case hex digits
+func isHex(str string) bool {
+	for _, c := range str {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
 	}
-	return deser.ObjType, nil
+	return true
 }
 
 func newDeserializedObj(content string) (*DeserializedGitObject, error) {
 	spaceIndex := strings.Index(content, " ")
+	nullIndex := strings.Index(content, "\x00")
+	//the header is "<type> <length>\x00"
+	if spaceIndex == -1 || nullIndex == -1 || nullIndex < spaceIndex {
+		return nil, errors.New("Invalid git object for deserialization, the header is malformed")
+	}
 	objType, err := AsGitObjectType(content[0:spaceIndex])
 	if err != nil {
 		return nil, err
 	}
-	nullIndex := strings.Index(content, "\x00")
 	length, err := strconv.Atoi(content[spaceIndex+1 : nullIndex])
 	if err != nil {
 		return nil, err
@@ -260,6 +213,8 @@ func AsGitObjectType(str string) (GitObjectType, error) {
 		return TREE, nil
 	case "commit":
 		return COMMIT, nil
+	case "tag":
+		return TAG, nil
 	default:
 		return "",
 			errors.New(
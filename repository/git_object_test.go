@@ -2,6 +2,8 @@ package repository
 
 import (
 	"crypto/sha1"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -29,6 +31,42 @@ func Test_SerializeAndDeserialize(t *testing.T) {
 
 }
 
+func Test_DeserializeMalformed(t *testing.T) {
+	fileFormatter := DefaultGitFileFormatter{}
+	//objects without a space, without a NUL byte or with a NUL byte inside of the type
+	for _, content := range []string{"blobgarbage", "blob 11", "bl\x00ob 5"} {
+		zippedContent, err := zipped([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fileFormatter.Deserialize(zippedContent)
+		if err == nil {
+			t.Fatalf("Object '%q' without a valid header should not be deserialized", content)
+		}
+	}
+}
+
+func Test_DeserializeTruncated(t *testing.T) {
+	fileFormatter := DefaultGitFileFormatter{}
+	serialized, err := fileFormatter.Serialize([]byte("Hello world"), BLOB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fileFormatter.Deserialize(serialized.Content[:len(serialized.Content)-6])
+	if err == nil {
+		t.Fatal("Truncated zlib stream should not be deserialized")
+	}
+	//the content is shorter than the length in the header
+	zippedContent, err := zipped([]byte("blob 11\x00Hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fileFormatter.Deserialize(zippedContent)
+	if err == nil {
+		t.Fatal("Truncated content should not be deserialized")
+	}
+}
+
 func Test_header(t *testing.T) {
 	data := []byte("Hello world")
 	header := header(data, BLOB)
@@ -71,3 +109,44 @@ func TestNewHash(t *testing.T) {
 		)
 	}
 }
+
+func TestExpandHash(t *testing.T) {
+	objPath, err := os.MkdirTemp("", "objects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(objPath)
+	objPath += "/"
+	err = os.Mkdir(objPath+"3b", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//only file names matter for the lookup
+	for _, name := range []string{
+		"0af1dd47d543b2166440b83bbf0ed0235173d8",
+		"0af1dd47d543b2166440b83bbf0ed0235173d9",
+		"1111dd47d543b2166440b83bbf0ed0235173d8",
+	} {
+		err = os.WriteFile(objPath+"3b/"+name, nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := ExpandHash(NewLooseStore(objPath), "3b1111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "3b1111dd47d543b2166440b83bbf0ed0235173d8" {
+		t.Fatalf("Wrong expanded hash %s", hash)
+	}
+	_, err = ExpandHash(NewLooseStore(objPath), "3b0af1")
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("Expected an ambiguity error, got %v", err)
+	}
+	for _, prefix := range []string{"3b0", "3B0AF1", "3b2222", "ffff"} {
+		_, err = ExpandHash(NewLooseStore(objPath), prefix)
+		if err == nil {
+			t.Errorf("Prefix %s was expanded", prefix)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/strogiyotec/dzhigit/pack"
+)
+
+//directory of packs inside of the object repository
+const Packs = "pack/"
+
+//Example: ".dzhigit/objects/" -> ".dzhigit/objects/pack/"
+func PackDir(objPath string) string {
+	return objPath + Packs
+}
+
+//Objects saved in packs of an object repository
+//Packs stay open between lookups, a pack is closed when it's removed from the directory
+type PackStore struct {
+	dir   string
+	packs map[string]*pack.Pack
+}
+
+//Example: ".dzhigit/objects/"
+func NewPackStore(objPath string) *PackStore {
+	return &PackStore{
+		dir:   PackDir(objPath),
+		packs: make(map[string]*pack.Pack),
+	}
+}
+
+//directory of packs
+func (s *PackStore) Dir() string {
+	return s.dir
+}
+
+//Packs that have an index, sorted by name
+func (s *PackStore) Packs() ([]*pack.Pack, error) {
+	indexes, err := filepath.Glob(s.dir + "pack-*" + pack.IndexExt)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(indexes)
+	listed := make(map[string]bool)
+	var packs []*pack.Pack
+	for _, indexPath := range indexes {
+		packPath := pack.PackPath(indexPath)
+		listed[packPath] = true
+		p, ok := s.packs[packPath]
+		if !ok {
+			p, err = pack.Open(packPath)
+			if err != nil {
+				return nil, err
+			}
+			s.packs[packPath] = p
+		}
+		packs = append(packs, p)
+	}
+	for packPath, p := range s.packs {
+		if !listed[packPath] {
+			p.Close()
+			delete(s.packs, packPath)
+		}
+	}
+	return packs, nil
+}
+
+func (s *PackStore) Has(hash Hash) bool {
+	_, err := s.find(hash)
+	return err == nil
+}
+
+func (s *PackStore) Get(hash Hash) (*DeserializedGitObject, error) {
+	p, err := s.find(hash)
+	if err != nil {
+		return nil, err
+	}
+	object, err := p.Get(string(hash))
+	if err != nil {
+		return nil, err
+	}
+	objType, err := AsGitObjectType(object.Type.String())
+	if err != nil {
+		return nil, err
+	}
+	return &DeserializedGitObject{
+		ObjType: objType,
+		Content: string(object.Data),
+	}, nil
+}
+
+//Every object is written as a separate pack, so objects should rather be packed together by pack-objects
+func (s *PackStore) Put(objType GitObjectType, content []byte) (Hash, error) {
+	packType, err := pack.TypeOf(string(objType))
+	if err != nil {
+		return "", err
+	}
+	object := pack.NewObject(packType, content)
+	if s.Has(Hash(object.Hash)) {
+		return Hash(object.Hash), nil
+	}
+	err = os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	_, err = pack.Create(s.dir, []pack.Object{object}, pack.DefaultOptions)
+	return Hash(object.Hash), err
+}
+
+//an object that is in several packs is visited once
+func (s *PackStore) Iterate(fn func(hash Hash) error) error {
+	packs, err := s.Packs()
+	if err != nil {
+		return err
+	}
+	var hashes []Hash
+	for _, p := range packs {
+		var packed []Hash
+		for _, entry := range p.Index().Entries() {
+			packed = append(packed, Hash(entry.Hash))
+		}
+		hashes = mergeHashes(hashes, packed)
+	}
+	for _, hash := range hashes {
+		err = fn(hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PackStore) Stat(hash Hash) (*ObjectInfo, error) {
+	p, err := s.find(hash)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p.Path())
+	if err != nil {
+		return nil, err
+	}
+	deser, err := s.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{
+		Type:    deser.ObjType,
+		Size:    int64(len(deser.Content)),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+//the first pack that has an object
+func (s *PackStore) find(hash Hash) (*pack.Pack, error) {
+	packs, err := s.Packs()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packs {
+		if p.Has(string(hash)) {
+			return p, nil
+		}
+	}
+	return nil, missingObjectError(hash)
+}
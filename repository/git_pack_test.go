@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/strogiyotec/dzhigit/pack"
+)
+
+func TestFileStore_packed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "objects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	objPath := dir + Objects
+	store := NewFileStore(objPath)
+	loose, err := store.Put(BLOB, []byte("loose"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed := pack.NewObject(pack.Tree, []byte{})
+	_, err = pack.Create(PackDir(objPath), []pack.Object{packed}, pack.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := Hash(packed.Hash)
+	if !store.Has(hash) || !store.Has(loose) || store.Loose.Has(hash) || store.Packs.Has(loose) {
+		t.Fatal("Both loose and packed objects should exist")
+	}
+	deser, err := store.Get(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deser.ObjType != TREE || deser.Content != "" {
+		t.Fatalf("Wrong packed object %s '%s'", deser.ObjType, deser.Content)
+	}
+	objType, err := TypeByHash(store, hash)
+	if err != nil || objType != TREE {
+		t.Fatalf("Wrong type of a packed object '%s', %v", objType, err)
+	}
+	expanded, err := ExpandHash(store, string(hash)[:6])
+	if err != nil || expanded != hash {
+		t.Fatalf("Wrong expanded hash '%s', %v", expanded, err)
+	}
+	//the loose copy of a packed object is listed once
+	_, err = store.Put(TREE, []byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes, err := Hashes(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 {
+		t.Fatalf("Wrong amount of objects %v", hashes)
+	}
+	missing := Hash("0000000000000000000000000000000000000000")
+	if store.Has(missing) {
+		t.Fatal("Object that is neither loose nor packed should not exist")
+	}
+	if _, err = store.Get(missing); err == nil {
+		t.Fatal("Missing object should not be read")
+	}
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Check that given name can be used as a reference name
+//The rules are the same as in 'git check-ref-format --branch'
+func ValidateRefName(name string) error {
+	invalid := func(reason string) error {
+		return errors.New(
+			fmt.Sprintf("'%s' is not a valid reference name: %s", name, reason),
+		)
+	}
+	switch {
+	case len(name) == 0:
+		return invalid("it's empty")
+	case name == "@":
+		return invalid("it can't be a single '@'")
+	case strings.HasPrefix(name, "-"):
+		return invalid("it can't begin with '-'")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return invalid("it can't begin or end with '/'")
+	case strings.HasSuffix(name, "."):
+		return invalid("it can't end with '.'")
+	case strings.Contains(name, "//"):
+		return invalid("it can't contain consecutive slashes")
+	case strings.Contains(name, ".."):
+		return invalid("it can't contain '..'")
+	case strings.Contains(name, "@{"):
+		return invalid("it can't contain '@{'")
+	}
+	for _, char := range name {
+		if char < 0x20 || char == 0x7f || strings.ContainsRune(" ~^:?*[\\", char) {
+			return invalid(fmt.Sprintf("it can't contain %q", char))
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return invalid("a component can't begin with '.'")
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return invalid("a component can't end with '.lock'")
+		}
+	}
+	return nil
+}
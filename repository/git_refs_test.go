@@ -0,0 +1,40 @@
+package repository
+
+import "testing"
+
+func TestValidateRefName(t *testing.T) {
+	valid := []string{"master", "feature/login", "fix-1.2", "user@host"}
+	for _, name := range valid {
+		if err := ValidateRefName(name); err != nil {
+			t.Fatalf("'%s' should be valid, got %s", name, err.Error())
+		}
+	}
+	invalid := []string{
+		"",
+		"@",
+		"-branch",
+		"/branch",
+		"branch/",
+		"branch.",
+		"a//b",
+		"a..b",
+		"a@{1}",
+		"with space",
+		"tilde~",
+		"caret^",
+		"colon:",
+		"question?",
+		"star*",
+		"bracket[",
+		"back\\slash",
+		".hidden",
+		"dir/.hidden",
+		"branch.lock",
+		"dir.lock/branch",
+	}
+	for _, name := range invalid {
+		if err := ValidateRefName(name); err == nil {
+			t.Fatalf("'%s' should be invalid", name)
+		}
+	}
+}
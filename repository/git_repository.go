@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	//path of the repository directory, discovery is skipped when it's set
+	DirEnv = "DZHIGIT_DIR"
+	//root of the working tree, the parent of the repository directory by default
+	WorkTreeEnv = "DZHIGIT_WORK_TREE"
+	//field of the config that marks a repository without a working tree
+	bareKey = "bare"
+)
+
+//A dzhigit repository on the disk
+type Repository struct {
+	Path     string     //the repository directory, e.g. "/home/user/project/.dzhigit"
+	WorkTree string     //root of the working tree, e.g. "/home/user/project", empty for a bare repository
+	Objects  *FileStore //loose and packed objects
+}
+
+//Open the repository that given directory belongs to
+//The directory and its parents are searched for .dzhigit or a bare repository like git does,
+//DZHIGIT_DIR and DZHIGIT_WORK_TREE override the repository and the working tree,
+//relative values are resolved against the current directory
+func Open(path string) (*Repository, error) {
+	gitRepoPath, err := findRepository(path)
+	if err != nil {
+		return nil, err
+	}
+	bare, err := isBare(gitRepoPath)
+	if err != nil {
+		return nil, err
+	}
+	workTree := WorkTreePath(gitRepoPath)
+	if bare {
+		workTree = ""
+	}
+	if env := os.Getenv(WorkTreeEnv); len(env) != 0 {
+		workTree, err = filepath.Abs(env)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Repository{
+		Path:     gitRepoPath,
+		WorkTree: workTree,
+		Objects:  NewFileStore(ObjPath(gitRepoPath)),
+	}, nil
+}
+
+func findRepository(path string) (string, error) {
+	if env := os.Getenv(DirEnv); len(env) != 0 {
+		gitRepoPath, err := filepath.Abs(env)
+		if err != nil {
+			return "", err
+		}
+		if !isRepository(gitRepoPath) {
+			return "", errors.New(
+				fmt.Sprintf("Dzhigit repository doesn't exist in %s", gitRepoPath),
+			)
+		}
+		return gitRepoPath, nil
+	}
+	start, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for dir := start; ; dir = filepath.Dir(dir) {
+		if isRepository(filepath.Join(dir, RepoDir)) {
+			return filepath.Join(dir, RepoDir), nil
+		}
+		if isRepository(dir) {
+			return dir, nil
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return "", errors.New(
+		fmt.Sprintf("Dzhigit repository doesn't exist in %s or any of its parent directories", start),
+	)
+}
+
+//a directory with HEAD and objects
+func isRepository(path string) bool {
+	return Exists(HeadPath(path)) && Exists(ObjPath(path))
+}
+
+//check if the config marks a repository as bare
+func isBare(gitRepoPath string) (bool, error) {
+	if !Exists(ConfigPath(gitRepoPath)) {
+		return false, nil
+	}
+	content, err := Reader(ConfigPath(gitRepoPath))
+	if err != nil {
+		return false, err
+	}
+	var config map[string]interface{}
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return false, errors.New(
+			fmt.Sprintf("Config %s is not valid: %s", ConfigPath(gitRepoPath), err.Error()),
+		)
+	}
+	bare, _ := config[bareKey].(bool)
+	return bare, nil
+}
+
+//A repository without a working tree
+func (r *Repository) Bare() bool {
+	return len(r.WorkTree) == 0
+}
+
+//Fails for a bare repository, commands that read or write files of the working tree call it first
+func (r *Repository) CheckWorkTree() error {
+	if r.Bare() {
+		return errors.New(
+			fmt.Sprintf("%s is a bare repository, this operation must be run in a working tree", r.Path),
+		)
+	}
+	return nil
+}
+
+//Content of the config file
+func (r *Repository) Config() ([]byte, error) {
+	return os.ReadFile(ConfigPath(r.Path))
+}
+
+//Path of the index file
+func (r *Repository) IndexPath() string {
+	return IndexPath(r.Path)
+}
+
+//Hashes of all branches and tags by their full names, e.g. "refs/heads/master"
+func (r *Repository) Refs() (map[string]Hash, error) {
+	refs := make(map[string]Hash)
+	err := filepath.Walk(
+		r.Path+Refs,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			//lock of a ref that is being written by another process
+			if IsLockFile(path) {
+				return nil
+			}
+			content, err := Reader(path)
+			if err != nil {
+				return err
+			}
+			hash, err := NewHash(strings.TrimSpace(string(content)))
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(r.Path, path)
+			if err != nil {
+				return err
+			}
+			refs[filepath.ToSlash(name)] = hash
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
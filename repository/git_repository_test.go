@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	//symbolic links of the temporary directory are resolved so paths can be compared
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Open(dir)
+	if err == nil {
+		t.Fatal("A directory without a repository should not be opened")
+	}
+	gitRepoPath := filepath.Join(dir, RepoDir)
+	err = Init(gitRepoPath, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(dir, "src", "inner")
+	err = os.MkdirAll(nested, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := Open(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Path != gitRepoPath || repo.WorkTree != dir {
+		t.Fatalf("Wrong repository %s with working tree %s", repo.Path, repo.WorkTree)
+	}
+	err = os.WriteFile(HeadsPath(gitRepoPath)+DefaultBranch, []byte("c1b0730e0133447badcfd47fd144e254807b06e1"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := repo.Refs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs["refs/heads/master"] != "c1b0730e0133447badcfd47fd144e254807b06e1" {
+		t.Fatalf("Wrong refs %v", refs)
+	}
+	//the repository and the working tree are given explicitly
+	os.Setenv(DirEnv, gitRepoPath)
+	os.Setenv(WorkTreeEnv, nested)
+	defer os.Unsetenv(DirEnv)
+	defer os.Unsetenv(WorkTreeEnv)
+	repo, err = Open(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Path != gitRepoPath || repo.WorkTree != nested {
+		t.Fatalf("Environment is ignored, got %s with working tree %s", repo.Path, repo.WorkTree)
+	}
+	os.Setenv(DirEnv, dir)
+	_, err = Open(nested)
+	if err == nil {
+		t.Fatal("DZHIGIT_DIR should point to a repository")
+	}
+}
+
+func TestOpen_bare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitRepoPath := filepath.Join(dir, "shared")
+	err = InitBare(gitRepoPath, []byte(`{"name":"Almas","email":"almas337519@gmail.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Exists(filepath.Join(gitRepoPath, RepoDir)) || !Exists(HeadPath(gitRepoPath)) {
+		t.Fatal("Files of a bare repository should be created in the directory itself")
+	}
+	err = InitBare(gitRepoPath, []byte("{}"))
+	if err == nil {
+		t.Fatal("A bare repository should not be created twice")
+	}
+	nested := filepath.Join(gitRepoPath, Refs, Heads)
+	repo, err := Open(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Path != gitRepoPath || !repo.Bare() {
+		t.Fatalf("Wrong repository %s with working tree '%s'", repo.Path, repo.WorkTree)
+	}
+	if repo.CheckWorkTree() == nil {
+		t.Fatal("A bare repository has no working tree")
+	}
+	config, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(config) != `{"bare":true,"email":"almas337519@gmail.com","name":"Almas"}` {
+		t.Fatalf("Wrong config %s", config)
+	}
+}
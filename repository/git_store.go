@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Storage of git objects
+//Objects are addressed by the hash of their type and content,
+//so an object that is put twice is stored once
+type ObjectStore interface {
+	//Check if an object exists
+	Has(hash Hash) bool
+	//Read an object
+	Get(hash Hash) (*DeserializedGitObject, error)
+	//Save an object, returns its hash
+	Put(objType GitObjectType, content []byte) (Hash, error)
+	//Call fn for every object in the order of hashes, the first error stops the iteration
+	Iterate(fn func(hash Hash) error) error
+	//Type, size and modification time of an object
+	Stat(hash Hash) (*ObjectInfo, error)
+}
+
+//Information about a stored object
+type ObjectInfo struct {
+	Type GitObjectType
+	//size of the content without the header
+	Size int64
+	//when the object was written, packed objects have the time of their pack
+	ModTime time.Time
+}
+
+//Objects of a repository on the disk
+//Objects are read from loose files and packs, new objects are saved as loose files
+type FileStore struct {
+	Loose *LooseStore
+	Packs *PackStore
+}
+
+//Example: ".dzhigit/objects/"
+func NewFileStore(objPath string) *FileStore {
+	return &FileStore{
+		Loose: NewLooseStore(objPath),
+		Packs: NewPackStore(objPath),
+	}
+}
+
+func (s *FileStore) Has(hash Hash) bool {
+	return s.Loose.Has(hash) || s.Packs.Has(hash)
+}
+
+func (s *FileStore) Get(hash Hash) (*DeserializedGitObject, error) {
+	if s.Loose.Has(hash) {
+		return s.Loose.Get(hash)
+	}
+	return s.Packs.Get(hash)
+}
+
+func (s *FileStore) Put(objType GitObjectType, content []byte) (Hash, error) {
+	return s.Loose.Put(objType, content)
+}
+
+//the same object may be loose and packed, it's visited once
+func (s *FileStore) Iterate(fn func(hash Hash) error) error {
+	hashes, err := Hashes(s.Loose)
+	if err != nil {
+		return err
+	}
+	packed, err := Hashes(s.Packs)
+	if err != nil {
+		return err
+	}
+	for _, hash := range mergeHashes(hashes, packed) {
+		err = fn(hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) Stat(hash Hash) (*ObjectInfo, error) {
+	if s.Loose.Has(hash) {
+		return s.Loose.Stat(hash)
+	}
+	return s.Packs.Stat(hash)
+}
+
+//All hashes of a store sorted
+func Hashes(store ObjectStore) ([]Hash, error) {
+	var hashes []Hash
+	err := store.Iterate(func(hash Hash) error {
+		hashes = append(hashes, hash)
+		return nil
+	})
+	return hashes, err
+}
+
+//Get type of a object by given hash
+func TypeByHash(store ObjectStore, hash Hash) (GitObjectType, error) {
+	info, err := store.Stat(hash)
+	if err != nil {
+		return "", err
+	}
+	return info.Type, nil
+}
+
+//Find the only object which hash starts with given prefix
+//A prefix has to be at least MinHashPrefix hex characters long
+func ExpandHash(store ObjectStore, prefix string) (Hash, error) {
+	if len(prefix) < MinHashPrefix || len(prefix) > sha1.Size*2 || !isHex(prefix) {
+		return "", errors.New(
+			fmt.Sprintf("'%s' is not a valid abbreviated hash", prefix),
+		)
+	}
+	var candidates []string
+	err := store.Iterate(func(hash Hash) error {
+		if strings.HasPrefix(string(hash), prefix) {
+			candidates = append(candidates, string(hash))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	switch len(candidates) {
+	case 0:
+		return "", errors.New(
+			fmt.Sprintf("Object with hash prefix %s doesn't exist", prefix),
+		)
+	case 1:
+		return NewHash(candidates[0])
+	default:
+		return "", errors.New(
+			fmt.Sprintf(
+				"short hash %s is ambiguous, candidates are:\n\t%s",
+				prefix,
+				strings.Join(candidates, "\n\t"),
+			),
+		)
+	}
+}
+
+func missingObjectError(hash Hash) error {
+	return errors.New(fmt.Sprintf("Object with hash %s doesn't exist", hash))
+}
+
+//union of two sorted lists of hashes without duplicates
+func mergeHashes(first []Hash, second []Hash) []Hash {
+	merged := make([]Hash, 0, len(first)+len(second))
+	merged = append(merged, first...)
+	merged = append(merged, second...)
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i] < merged[j]
+	})
+	unique := merged[:0]
+	for _, hash := range merged {
+		if len(unique) == 0 || unique[len(unique)-1] != hash {
+			unique = append(unique, hash)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestObjectStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "objects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stores := map[string]ObjectStore{
+		"loose":  NewLooseStore(dir + "/loose" + Objects),
+		"pack":   NewPackStore(dir + "/pack" + Objects),
+		"file":   NewFileStore(dir + "/file" + Objects),
+		"memory": NewMemoryStore(),
+	}
+	for name, store := range stores {
+		hash, err := store.Put(BLOB, []byte("Some random data"))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		//computed by "git hash-object"
+		if hash != "36027a135447ed1cbea4faa9c1f6ce26e400c5ac" {
+			t.Fatalf("%s: wrong hash %s", name, hash)
+		}
+		again, err := store.Put(BLOB, []byte("Some random data"))
+		if err != nil || again != hash {
+			t.Fatalf("%s: the same object has a different hash %s, %v", name, again, err)
+		}
+		tree, err := store.Put(TREE, []byte{})
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !store.Has(hash) || !store.Has(tree) || store.Has(Hash("0000000000000000000000000000000000000000")) {
+			t.Fatalf("%s: wrong objects", name)
+		}
+		deser, err := store.Get(hash)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if deser.ObjType != BLOB || deser.Content != "Some random data" {
+			t.Fatalf("%s: wrong object %s '%s'", name, deser.ObjType, deser.Content)
+		}
+		info, err := store.Stat(tree)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if info.Type != TREE || info.Size != 0 || info.ModTime.IsZero() {
+			t.Fatalf("%s: wrong info %+v", name, info)
+		}
+		hashes, err := Hashes(store)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if len(hashes) != 2 || hashes[0] > hashes[1] {
+			t.Fatalf("%s: wrong hashes %v", name, hashes)
+		}
+		expanded, err := ExpandHash(store, string(tree)[:5])
+		if err != nil || expanded != tree {
+			t.Fatalf("%s: wrong expanded hash %s, %v", name, expanded, err)
+		}
+	}
+}
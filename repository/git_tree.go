@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//single entry of a tree object
+type TreeEntry struct {
+	Mode Mode
+	Name string //name inside of the tree, without directories
+	Hash Hash
+}
+
+//type of an object the entry points to
+func (entry TreeEntry) ObjType() GitObjectType {
+	if entry.Mode == DIR {
+		return TREE
+	}
+	return BLOB
+}
+
+//Encode entries in the binary format of git
+//Each entry is "<mode> <name>\0<20 bytes of hash>" without a separator,
+//entries are sorted by name where a name of a subtree is compared
+//as if it ended with "/", so hashes of trees match the ones of git
+func EncodeTree(entries []TreeEntry) ([]byte, error) {
+	sorted := make([]TreeEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return treeSortKey(sorted[i]) < treeSortKey(sorted[j])
+	})
+	var buffer bytes.Buffer
+	for _, entry := range sorted {
+		if len(entry.Name) == 0 || strings.ContainsAny(entry.Name, "/\x00") {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid tree entry name '%s'", entry.Name),
+			)
+		}
+		raw, err := hex.DecodeString(string(entry.Hash))
+		if err != nil || len(raw) != sha1.Size {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid hash '%s' of tree entry '%s'", entry.Hash, entry.Name),
+			)
+		}
+		buffer.WriteString(fmt.Sprintf("%s %s\x00", entry.Mode, entry.Name))
+		buffer.Write(raw)
+	}
+	return buffer.Bytes(), nil
+}
+
+//Decode the content of a tree object
+//Trees written by older versions in the text format
+//"<mode> <type> <hash>\t<name>" are decoded as well
+func DecodeTree(content string) ([]TreeEntry, error) {
+	if IsTextTree(content) {
+		return decodeTextTree(content)
+	}
+	var entries []TreeEntry
+	for len(content) != 0 {
+		space := strings.Index(content, " ")
+		null := strings.Index(content, "\x00")
+		if space == -1 || null < space || null+1+sha1.Size > len(content) {
+			return nil, errors.New("Invalid tree object, an entry is truncated")
+		}
+		mode, err := AsMode(content[:space])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(
+			entries,
+			TreeEntry{
+				Mode: mode,
+				Name: content[space+1 : null],
+				Hash: Hash(hex.EncodeToString([]byte(content[null+1 : null+1+sha1.Size]))),
+			},
+		)
+		content = content[null+1+sha1.Size:]
+	}
+	return entries, nil
+}
+
+//Check if a tree was written in the old text format,
+//a binary tree always has a null byte after the name of each entry
+func IsTextTree(content string) bool {
+	return len(content) != 0 && !strings.Contains(content, "\x00")
+}
+
+//name used to order entries, subtrees are compared as "name/"
+func treeSortKey(entry TreeEntry) string {
+	if entry.Mode == DIR {
+		return entry.Name + "/"
+	}
+	return entry.Name
+}
+
+func decodeTextTree(content string) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	for _, line := range strings.Split(content, "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		parts := strings.SplitN(line, "\t", 2)
+		fields := strings.Fields(parts[0])
+		if len(parts) != 2 || len(fields) != 3 {
+			return nil, errors.New(
+				fmt.Sprintf("Invalid tree entry '%s'", line),
+			)
+		}
+		mode, err := AsMode(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		hash, err := NewHash(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, TreeEntry{Mode: mode, Name: parts[1], Hash: hash})
+	}
+	return entries, nil
+}
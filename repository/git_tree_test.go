@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"testing"
+)
+
+//hashes of blobs "hello\n", "#!/bin/sh\necho hi\n" and "x" as computed by git
+const (
+	helloBlob = Hash("ce013625030ba8dba906f756967f9e9ca394464a")
+	runBlob   = Hash("4163036efa65bd4a469e752267498f01ea36a55c")
+	xBlob     = Hash("c1b0730e0133447badcfd47fd144e254807b06e1")
+)
+
+func TestEncodeTree_gitCompatible(t *testing.T) {
+	formatter := DefaultGitFileFormatter{}
+	cases := []struct {
+		name     string
+		entries  []TreeEntry
+		expected Hash
+	}{
+		{"empty tree", nil, "4b825dc642cb6eb9a060e54bf8d69288fbee4904"},
+		{
+			"single blob",
+			[]TreeEntry{{Mode: FILE, Name: "inner.txt", Hash: xBlob}},
+			"d0fd48f8a028d89b630a5c6bb9f94b12ff4c3853",
+		},
+		{
+			//"lib" is sorted as "lib/" so it goes after "lib-a" and "lib.go"
+			"git sort order",
+			[]TreeEntry{
+				{Mode: DIR, Name: "lib", Hash: "d0fd48f8a028d89b630a5c6bb9f94b12ff4c3853"},
+				{Mode: EXECUTABLE, Name: "run.sh", Hash: runBlob},
+				{Mode: FILE, Name: "lib.go", Hash: xBlob},
+				{Mode: FILE, Name: "hello.txt", Hash: helloBlob},
+				{Mode: FILE, Name: "lib-a", Hash: xBlob},
+			},
+			"46ba25ff127bb2c57449f39e13a32c83c42f7cb1",
+		},
+	}
+	for _, c := range cases {
+		content, err := EncodeTree(c.entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ser, err := formatter.Serialize(content, TREE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ser.Hash != c.expected {
+			t.Fatalf("%s: git hash '%s' expected, got '%s'", c.name, c.expected, ser.Hash)
+		}
+	}
+}
+
+func TestBlob_gitCompatible(t *testing.T) {
+	formatter := DefaultGitFileFormatter{}
+	ser, err := formatter.Serialize([]byte("hello\n"), BLOB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ser.Hash != helloBlob {
+		t.Fatalf("git hash '%s' expected, got '%s'", helloBlob, ser.Hash)
+	}
+}
+
+func TestDecodeTree(t *testing.T) {
+	entries := []TreeEntry{
+		{Mode: FILE, Name: "a b.txt", Hash: helloBlob},
+		{Mode: DIR, Name: "dir", Hash: xBlob},
+	}
+	content, err := EncodeTree(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsTextTree(string(content)) {
+		t.Fatal("Encoded tree should be binary")
+	}
+	decoded, err := DecodeTree(string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0] != entries[0] || decoded[1] != entries[1] {
+		t.Fatalf("Decoded entries don't match encoded ones, got %v", decoded)
+	}
+	if decoded[1].ObjType() != TREE {
+		t.Fatalf("Entry with mode %s should be a tree", DIR)
+	}
+	_, err = DecodeTree(string(content[:len(content)-1]))
+	if err == nil {
+		t.Fatal("Truncated tree should not be decoded")
+	}
+}
+
+func TestDecodeTree_textFormat(t *testing.T) {
+	content := "100644 blob " + string(helloBlob) + "\ta b.txt\n040000 tree " + string(xBlob) + "\tdir\n"
+	if !IsTextTree(content) {
+		t.Fatal("Tree of older versions should be detected")
+	}
+	decoded, err := DecodeTree(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0].Name != "a b.txt" || decoded[1].Mode != DIR {
+		t.Fatalf("Wrong entries of a text tree %v", decoded)
+	}
+}
+
+func TestEncodeTree_invalidName(t *testing.T) {
+	_, err := EncodeTree([]TreeEntry{{Mode: FILE, Name: "a/b", Hash: helloBlob}})
+	if err == nil {
+		t.Fatal("Name with a slash can't be a tree entry")
+	}
+}